@@ -0,0 +1,344 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ArtifactKind is a bit mask of the artifacts found in an epoch, 0 means the epoch is clean
+type ArtifactKind int
+
+const (
+	// ArtifactAmplitude is a peak-to-peak amplitude over the threshold on any channel (electrode pops, movement)
+	ArtifactAmplitude ArtifactKind = 1 << iota
+	// ArtifactFlat is a flat-line channel, usually a disconnected electrode
+	ArtifactFlat
+	// ArtifactBlink is a slow large deflection on the frontal channels
+	ArtifactBlink
+	// ArtifactMuscle is too much high-frequency power on any channel
+	ArtifactMuscle
+)
+
+func (a ArtifactKind) String() string {
+	if a == 0 {
+		return "clean"
+	}
+
+	var kinds []string
+	for _, k := range []struct {
+		kind ArtifactKind
+		name string
+	}{
+		{ArtifactAmplitude, "amplitude"},
+		{ArtifactFlat, "flat"},
+		{ArtifactBlink, "blink"},
+		{ArtifactMuscle, "muscle"},
+	} {
+		if a&k.kind != 0 {
+			kinds = append(kinds, k.name)
+		}
+	}
+	return strings.Join(kinds, "|")
+}
+
+// ArtifactPolicy tells what to do with the frames of the flagged epochs
+type ArtifactPolicy int
+
+const (
+	// ArtifactKeep leaves the features as they are
+	ArtifactKeep ArtifactPolicy = iota
+	// ArtifactDrop removes the flagged frames
+	ArtifactDrop
+	// ArtifactInterpolate replaces the flagged frames with the linear interpolation of the closest clean frames
+	ArtifactInterpolate
+	// ArtifactDownWeight keeps the flagged frames but gives them ArtifactOptions.Weight instead of 1
+	// so they count less in the k-means and em sums (see KMeansOptions.Weights)
+	ArtifactDownWeight
+)
+
+func (p ArtifactPolicy) String() string {
+	switch p {
+	case ArtifactKeep:
+		return "keep"
+	case ArtifactDrop:
+		return "drop"
+	case ArtifactInterpolate:
+		return "interpolate"
+	case ArtifactDownWeight:
+		return "down-weight"
+	default:
+		return fmt.Sprintf("ArtifactPolicy(%d)", int(p))
+	}
+}
+
+// ArtifactOptions are the thresholds of the artifact detection, in the units of the recording (µV for the headset)
+// A threshold which is 0 turns its check off
+type ArtifactOptions struct {
+	// PeakToPeak is the largest allowed peak-to-peak amplitude on any channel
+	PeakToPeak float64
+	// Flat is the smallest allowed standard deviation on any channel
+	Flat float64
+	// Blink is the largest allowed peak-to-peak amplitude of the smoothed frontal channels
+	Blink float64
+	// BlinkChannels are the frontal channels the blinks are looked for in, the missing ones are skipped
+	BlinkChannels []string
+	// BlinkSmoothingMs is the length of the moving average which removes everything but the slow blink waves
+	BlinkSmoothingMs int
+	// Muscle is the largest allowed part of the power of a channel in MuscleBand
+	Muscle float64
+	// MuscleBand are the frequencies of the muscle activity
+	MuscleBand Band
+	// Policy is applied to the frames of the flagged epochs
+	Policy ArtifactPolicy
+	// Weight is the weight of the flagged frames with ArtifactDownWeight
+	Weight float64
+}
+
+// DefaultArtifactOptions returns the thresholds for the headset, which keep the flagged frames
+func DefaultArtifactOptions() ArtifactOptions {
+	return ArtifactOptions{
+		PeakToPeak:       150,
+		Flat:             0.5,
+		Blink:            75,
+		BlinkChannels:    []string{"Fp1", "Fp2", "Fpz"},
+		BlinkSmoothingMs: 50,
+		Muscle:           0.4,
+		MuscleBand:       Band{Name: "emg", Low: 30, High: 100, LowInclusive: true, HighInclusive: true},
+		Policy:           ArtifactKeep,
+		Weight:           0.1,
+	}
+}
+
+func (o ArtifactOptions) String() string {
+	return fmt.Sprintf("ptp=%g flat=%g blink=%g%v/%dms muscle=%g%s policy=%s weight=%g",
+		o.PeakToPeak, o.Flat, o.Blink, o.BlinkChannels, o.BlinkSmoothingMs, o.Muscle, o.MuscleBand, o.Policy, o.Weight)
+}
+
+// EEGArtifacts are the options GetFourierForFile rejects artifacts with
+// With ArtifactDownWeight the loaders keep the flagged frames and only LoadWeightedFourierForFile returns their weights
+var EEGArtifacts = DefaultArtifactOptions()
+
+// epochBounds returns the [first, last) samples of every epoch, which are the frames cut by CutSliceIntoFrames
+func epochBounds(samples int, sampleRate int, frameLen int, frameStep int) [][2]int {
+	length := int((float64(frameLen) / 1000.0) * float64(sampleRate))
+	step := int((float64(frameStep) / 1000.0) * float64(sampleRate))
+	if step <= 0 || samples < length {
+		return nil
+	}
+
+	bounds := make([][2]int, (samples-length)/step, (samples-length)/step)
+	for i := range bounds {
+		bounds[i] = [2]int{i * step, i*step + length}
+	}
+	return bounds
+}
+
+func peakToPeak(x []float64) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+
+	min, max := x[0], x[0]
+	for _, v := range x {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return max - min
+}
+
+func standardDeviation(x []float64) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+
+	return statsOfValues(x).StandardDeviation()[0]
+}
+
+// movingAverage smooths x with a centered window of the given number of samples
+func movingAverage(x []float64, window int) []float64 {
+	smooth := make([]float64, len(x), len(x))
+	if window <= 1 {
+		copy(smooth, x)
+		return smooth
+	}
+
+	sums := make([]float64, len(x)+1, len(x)+1)
+	for i, v := range x {
+		sums[i+1] = sums[i] + v
+	}
+	for i := range x {
+		from := Max(i-window/2, 0)
+		to := Min(i+window-window/2, len(x))
+		smooth[i] = (sums[to] - sums[from]) / float64(to-from)
+	}
+	return smooth
+}
+
+// bandPowerRatio returns the part of the power of the frame's spectrum (without DC) which falls in the band
+func bandPowerRatio(coefficients []Complex, sampleRate int, band Band) float64 {
+	var inBand, total float64
+	for j := 1; j < len(coefficients); j++ {
+		power := Power(coefficients[j])
+		total += power
+		if band.Contains(IndToFreq(j, sampleRate, len(coefficients))) {
+			inBand += power
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return inBand / total
+}
+
+// DetectArtifacts returns the artifacts in every epoch of the recording
+// The epochs are the frames getFourier cuts with the same frameLen and frameStep, so the mask lines up with the features
+func DetectArtifacts(rec EEGRecording, frameLen int, frameStep int, options ArtifactOptions) []ArtifactKind {
+	if len(rec.Data) == 0 {
+		return nil
+	}
+
+	bounds := epochBounds(len(rec.Data[0]), rec.SampleRate, frameLen, frameStep)
+	mask := make([]ArtifactKind, len(bounds), len(bounds))
+
+	for _, d := range rec.Data {
+		for i, b := range bounds {
+			epoch := d[b[0]:b[1]]
+			if options.PeakToPeak > 0 && peakToPeak(epoch) > options.PeakToPeak {
+				mask[i] |= ArtifactAmplitude
+			}
+			if options.Flat > 0 && standardDeviation(epoch) < options.Flat {
+				mask[i] |= ArtifactFlat
+			}
+		}
+
+		if options.Muscle > 0 {
+			frames := cutElectrodeIntoFrames(d, rec.SampleRate, frameLen, frameStep, false)
+			for i, f := range frames {
+				coefficients, _ := FftReal(f)
+				if i < len(mask) && bandPowerRatio(coefficients, rec.SampleRate, options.MuscleBand) > options.Muscle {
+					mask[i] |= ArtifactMuscle
+				}
+			}
+		}
+	}
+
+	if options.Blink > 0 {
+		window := options.BlinkSmoothingMs * rec.SampleRate / 1000
+		for _, label := range options.BlinkChannels {
+			c := findChannel(rec, label)
+			if c == -1 {
+				continue
+			}
+
+			smooth := movingAverage(rec.Data[c], window)
+			for i, b := range bounds {
+				if peakToPeak(smooth[b[0]:b[1]]) > options.Blink {
+					mask[i] |= ArtifactBlink
+				}
+			}
+		}
+	}
+
+	return mask
+}
+
+// CleanEpochs returns true for every epoch in the mask without artifacts
+func CleanEpochs(mask []ArtifactKind) []bool {
+	clean := make([]bool, len(mask), len(mask))
+	for i, m := range mask {
+		clean[i] = m == 0
+	}
+	return clean
+}
+
+func countClean(mask []ArtifactKind) int {
+	clean := 0
+	for _, m := range mask {
+		if m == 0 {
+			clean++
+		}
+	}
+	return clean
+}
+
+// interpolateFrames replaces the flagged frames with the linear interpolation of the closest clean frames before and after them
+// The flagged frames at the ends take the closest clean one, nothing changes if there are no clean frames
+func interpolateFrames(features [][]float64, mask []ArtifactKind) [][]float64 {
+	interpolated := make([][]float64, len(features), len(features))
+	copy(interpolated, features)
+
+	previous := -1
+	for i := 0; i <= len(features); i++ {
+		if i < len(features) && mask[i] != 0 {
+			continue
+		}
+
+		for j := previous + 1; j < i; j++ {
+			switch {
+			case previous == -1 && i == len(features):
+			case previous == -1:
+				interpolated[j] = append([]float64{}, features[i]...)
+			case i == len(features):
+				interpolated[j] = append([]float64{}, features[previous]...)
+			default:
+				t := float64(j-previous) / float64(i-previous)
+				interpolated[j] = make([]float64, len(features[j]), len(features[j]))
+				for k := range interpolated[j] {
+					interpolated[j][k] = (1-t)*features[previous][k] + t*features[i][k]
+				}
+			}
+		}
+		previous = i
+	}
+
+	return interpolated
+}
+
+// ApplyArtifactMask applies the policy to the feature frames of the epochs in the mask
+// and returns the remaining frames with their weights
+func ApplyArtifactMask(features [][]float64, mask []ArtifactKind, policy ArtifactPolicy, weight float64) ([][]float64, []float64, error) {
+	if len(features) != len(mask) {
+		return nil, nil, fmt.Errorf("%d frames for a mask of %d epochs", len(features), len(mask))
+	}
+
+	weights := make([]float64, 0, len(features))
+	switch policy {
+	case ArtifactKeep, ArtifactInterpolate:
+		for range features {
+			weights = append(weights, 1)
+		}
+		if policy == ArtifactInterpolate {
+			features = interpolateFrames(features, mask)
+		}
+		return features, weights, nil
+	case ArtifactDrop:
+		kept := make([][]float64, 0, len(features))
+		for i := range features {
+			if mask[i] == 0 {
+				kept = append(kept, features[i])
+				weights = append(weights, 1)
+			}
+		}
+		return kept, weights, nil
+	case ArtifactDownWeight:
+		for i := range features {
+			if mask[i] == 0 {
+				weights = append(weights, 1)
+			} else {
+				weights = append(weights, weight)
+			}
+		}
+		return features, weights, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown artifact policy %s", policy)
+	}
+}
+
+// CleanFourierForRecording returns the feature vectors of the recording (see GetFourierForRecording)
+// after the artifacts are detected and options.Policy is applied, together with the weight of every frame
+func CleanFourierForRecording(rec EEGRecording, frameLen int, frameStep int, options ArtifactOptions) ([][]float64, []float64, error) {
+	mask := DetectArtifacts(rec, frameLen, frameStep, options)
+	return ApplyArtifactMask(GetFourierForRecording(rec, frameLen, frameStep), mask, options.Policy, options.Weight)
+}
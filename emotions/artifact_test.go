@@ -0,0 +1,91 @@
+package emotions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectArtifacts(t *testing.T) {
+	rec := sineRecording(10.0, 500, 5, []string{"Fp1", "Cz", "O1"})
+	for i := range rec.Data[0] {
+		rec.Data[0][i] *= 10
+	}
+
+	options := DefaultArtifactOptions()
+	clean := DetectArtifacts(rec, 1000, 1000, options)
+	assert.Equal(t, len(GetFourierForRecording(rec, 1000, 1000)), len(clean))
+	assert.Equal(t, []bool{true, true, true, true}, CleanEpochs(clean))
+
+	// a blink on Fp1 in the second second, a 80Hz burst on Cz in the third and O1 falls off in the fourth
+	for i := 500; i < 700; i++ {
+		rec.Data[0][i] += 100 * math.Sin(math.Pi*float64(i-500)/200)
+	}
+	for i := 1000; i < 1500; i++ {
+		rec.Data[1][i] += 5 * math.Sin(2*math.Pi*80*float64(i)/500)
+	}
+	for i := 1500; i < 2000; i++ {
+		rec.Data[2][i] = 0
+	}
+
+	mask := DetectArtifacts(rec, 1000, 1000, options)
+	assert.Equal(t, []ArtifactKind{0, ArtifactBlink, ArtifactMuscle, ArtifactFlat}, mask)
+
+	features := [][]float64{{0}, {1}, {2}, {3}}
+	flagged := []ArtifactKind{0, ArtifactBlink, ArtifactMuscle, 0}
+
+	dropped, weights, err := ApplyArtifactMask(features, flagged, ArtifactDrop, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]float64{{0}, {3}}, dropped)
+	assert.Equal(t, []float64{1, 1}, weights)
+
+	interpolated, _, _ := ApplyArtifactMask(features, []ArtifactKind{ArtifactBlink, 0, ArtifactBlink, 0}, ArtifactInterpolate, 0)
+	assert.Equal(t, [][]float64{{1}, {1}, {2}, {3}}, interpolated)
+
+	_, weights, _ = ApplyArtifactMask(features, flagged, ArtifactDownWeight, 0.1)
+	assert.Equal(t, []float64{1, 0.1, 0.1, 1}, weights)
+}
+
+func TestLoadWeightedFourier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifact")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// four seconds of two channels with an electrode pop in the third second
+	var ticks strings.Builder
+	for i := 0; i < 4*DefaultEEGSampleRate; i++ {
+		sample := 10 * math.Sin(2*math.Pi*10*float64(i)/DefaultEEGSampleRate)
+		pop := 0.0
+		if i == 2*DefaultEEGSampleRate+100 {
+			pop = 500
+		}
+		fmt.Fprintf(&ticks, "%f %f\n", sample, sample+pop)
+	}
+	filename := filepath.Join(dir, "pop.txt")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(ticks.String()), 0644))
+
+	defer func(options ArtifactOptions) { EEGArtifacts = options }(EEGArtifacts)
+	EEGArtifacts = DefaultArtifactOptions()
+	EEGArtifacts.Policy = ArtifactDownWeight
+
+	features, weights, err := LoadWeightedFourierForFile(filename, AllElectrodes, 1000, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{1, 1, 0.1}, weights)
+	assert.Len(t, features, 3)
+	assert.Len(t, features[0], 2*len(EEGBands))
+
+	unweighted, err := LoadFourierForFile(filename, AllElectrodes, 1000, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, features, unweighted)
+
+	EEGArtifacts.Policy = ArtifactDrop
+	_, weights, err = LoadWeightedFourierForFile(filename, AllElectrodes, 1000, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{1, 1}, weights)
+}
@@ -0,0 +1,195 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+)
+
+// getDifferentialEntropy takes fourier coefficients for each frame for an electrode
+// and returns an array frameNum x len(EEGBands) with the differential entropy of the signal in every band
+// The signal in a band is taken to be gaussian, so its differential entropy is ½ log(2πeσ²)
+// where the variance σ² is the power in the band (by Parseval's theorem)
+func getDifferentialEntropy(coefficients [][]Complex, sampleRate int) [][]float64 {
+	de := make([][]float64, len(coefficients), len(coefficients))
+
+	for i := range coefficients {
+		n := 2 * (len(coefficients[i]) - 1)
+		variances := make([]float64, len(EEGBands), len(EEGBands))
+		for j := range coefficients[i] {
+			w := EEGBands.Index(IndToFreq(j, sampleRate, len(coefficients[i])))
+			if w == -1 {
+				continue
+			}
+			variances[w] += 2 * Power(coefficients[i][j]) / float64(n*n)
+		}
+
+		de[i] = make([]float64, len(EEGBands), len(EEGBands))
+		for w, v := range variances {
+			de[i][w] = 0.5 * (math.Log(2*math.Pi*math.E) + safeLog(v))
+		}
+	}
+
+	return de
+}
+
+// GetDifferentialEntropyForRecording returns the differential entropy of every band for each frame
+// in the layout of getFourier: numFrames x (numEl * len(EEGBands))
+func GetDifferentialEntropyForRecording(rec EEGRecording, frameLen int, frameStep int) [][]float64 {
+	if len(rec.Data) == 0 {
+		return nil
+	}
+
+	var de [][]float64
+	for c, d := range rec.Data {
+		frames := cutElectrodeIntoFrames(d, rec.SampleRate, frameLen, frameStep, false)
+		electrode := getDifferentialEntropy(fourierElectrode(frames), rec.SampleRate)
+		if c == 0 {
+			de = make([][]float64, len(electrode), len(electrode))
+			for i := range de {
+				de[i] = make([]float64, 0, len(rec.Data)*len(EEGBands))
+			}
+		}
+
+		for i := range electrode {
+			de[i] = append(de[i], electrode[i]...)
+		}
+	}
+	return de
+}
+
+// CaudalPairs returns the (frontal, posterior) channel indices of the electrodes which are mirror images of each other
+// across the coronal plane (Fp1-O1, F3-P3, Fz-Pz...) in the order of the frontal electrodes in the montage
+func (m Montage) CaudalPairs() [][2]int {
+	var pairs [][2]int
+	for i, frontal := range m.Electrodes {
+		if !frontal.Scalp || frontal.Y < EPS {
+			continue
+		}
+
+		mirror := frontal
+		mirror.Y = -mirror.Y
+		for j, posterior := range m.Electrodes {
+			if posterior.Scalp && posterior.Y < -EPS && distance(mirror, posterior) < EPS {
+				pairs = append(pairs, [2]int{i, j})
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+func pairLabels(channels []string, pairs [][2]int) [][2]string {
+	labels := make([][2]string, len(pairs), len(pairs))
+	for i, p := range pairs {
+		labels[i] = [2]string{channels[p[0]], channels[p[1]]}
+	}
+	return labels
+}
+
+// Asymmetry are the electrode pairs the asymmetry features are computed over
+type Asymmetry struct {
+	// Hemispheric are the (left, right) pairs of DASM and RASM
+	Hemispheric [][2]string
+	// Caudal are the (frontal, posterior) pairs of DCAU
+	Caudal [][2]string
+}
+
+// NewAsymmetry takes the hemispheric and caudal pairs of the channels from their 10-20 positions (see Montage)
+func NewAsymmetry(channels []string) (Asymmetry, error) {
+	montage, err := NewMontage(channels)
+	if err != nil {
+		return Asymmetry{}, err
+	}
+
+	return Asymmetry{
+		Hemispheric: pairLabels(channels, montage.HemisphericPairs()),
+		Caudal:      pairLabels(channels, montage.CaudalPairs()),
+	}, nil
+}
+
+// Names returns the name of every coordinate of the asymmetry features, e.g. "DASM Fp1-Fp2 α"
+func (a Asymmetry) Names() []string {
+	var names []string
+	for _, kind := range []struct {
+		name  string
+		pairs [][2]string
+	}{
+		{"DASM", a.Hemispheric},
+		{"RASM", a.Hemispheric},
+		{"DCAU", a.Caudal},
+	} {
+		for _, p := range kind.pairs {
+			for _, band := range EEGBands.Names() {
+				names = append(names, fmt.Sprintf("%s %s-%s %s", kind.name, p[0], p[1], band))
+			}
+		}
+	}
+	return names
+}
+
+func pairIndices(channels []string, pairs [][2]string) ([][2]int, error) {
+	indices := make([][2]int, len(pairs), len(pairs))
+	for i, p := range pairs {
+		indices[i] = [2]int{channelIndex(channels, p[0]), channelIndex(channels, p[1])}
+		if indices[i][0] == -1 || indices[i][1] == -1 {
+			return nil, fmt.Errorf("missing channels for %s-%s in %v", p[0], p[1], channels)
+		}
+	}
+	return indices, nil
+}
+
+// Features takes the differential entropy of every frame (see GetDifferentialEntropyForRecording) of a recording with the given channels
+// and returns for every frame, band-minor:
+//
+//	DASM = DE(left) - DE(right) for every hemispheric pair and band
+//	RASM = P(left) / P(right) for every hemispheric pair and band
+//	DCAU = DE(frontal) - DE(posterior) for every caudal pair and band
+//
+// which for the 19 electrodes of the headset is (2 * 8 + 7) * len(EEGBands) coordinates (see Names)
+// RASM is the ratio of the band powers P = exp(2 DE) / 2πe instead of the ratio of the DEs
+// which are negative or close to 0 for powers up to 1/2πe and would flip the sign of the ratio or blow it up
+func (a Asymmetry) Features(de [][]float64, channels []string) ([][]float64, error) {
+	hemispheric, err := pairIndices(channels, a.Hemispheric)
+	if err != nil {
+		return nil, err
+	}
+	caudal, err := pairIndices(channels, a.Caudal)
+	if err != nil {
+		return nil, err
+	}
+
+	bands := len(EEGBands)
+	features := make([][]float64, len(de), len(de))
+	for i := range de {
+		if len(de[i]) != len(channels)*bands {
+			return nil, fmt.Errorf("frame %d has %d coordinates instead of %d channels x %d bands", i, len(de[i]), len(channels), bands)
+		}
+
+		features[i] = make([]float64, 0, (2*len(hemispheric)+len(caudal))*bands)
+		for _, p := range hemispheric {
+			for k := 0; k < bands; k++ {
+				features[i] = append(features[i], de[i][p[0]*bands+k]-de[i][p[1]*bands+k])
+			}
+		}
+		for _, p := range hemispheric {
+			for k := 0; k < bands; k++ {
+				features[i] = append(features[i], math.Exp(2*(de[i][p[0]*bands+k]-de[i][p[1]*bands+k])))
+			}
+		}
+		for _, p := range caudal {
+			for k := 0; k < bands; k++ {
+				features[i] = append(features[i], de[i][p[0]*bands+k]-de[i][p[1]*bands+k])
+			}
+		}
+	}
+	return features, nil
+}
+
+// GetAsymmetryForRecording returns the DASM, RASM and DCAU features of every frame of the recording (see Asymmetry.Features)
+func GetAsymmetryForRecording(rec EEGRecording, frameLen int, frameStep int) ([][]float64, error) {
+	asymmetry, err := NewAsymmetry(rec.Channels)
+	if err != nil {
+		return nil, err
+	}
+	return asymmetry.Features(GetDifferentialEntropyForRecording(rec, frameLen, frameStep), rec.Channels)
+}
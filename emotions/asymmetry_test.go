@@ -0,0 +1,59 @@
+package emotions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsymmetry(t *testing.T) {
+	montage, err := NewMontage(DefaultEEGChannels)
+	assert.Nil(t, err)
+	// Fp1-O1, Fp2-O2, F7-T5, F8-T6, Fz-Pz, F3-P3, F4-P4
+	assert.Equal(t, [][2]int{{0, 17}, {1, 18}, {2, 15}, {3, 16}, {4, 10}, {5, 11}, {6, 12}}, montage.CaudalPairs())
+
+	asymmetry, err := NewAsymmetry(DefaultEEGChannels)
+	assert.Nil(t, err)
+	names := asymmetry.Names()
+	assert.Equal(t, (2*8+7)*len(EEGBands), len(names))
+	assert.Equal(t, "DASM Fp1-Fp2 "+EEGBands[0].Name, names[0])
+
+	// the left channel has twice the amplitude of the right one, so DASM is ½ log 4 in α
+	rec := sineRecording(10.0, 500, 4, []string{"C3", "C4", "Cz", "Pz"})
+	multiply(&rec.Data[0], 2)
+	features, err := GetAsymmetryForRecording(rec, 1000, 500)
+	assert.Nil(t, err)
+
+	asymmetry, _ = NewAsymmetry(rec.Channels)
+	assert.Equal(t, [][2]string{{"C3", "C4"}}, asymmetry.Hemispheric)
+	assert.Equal(t, len(asymmetry.Names()), len(features[0]))
+
+	α := EEGBands.IndexOf("α")
+	for _, f := range features {
+		assert.InDelta(t, 0.5*math.Log(4), f[α], 1e-6)
+	}
+
+	// the power of the left channel is 4 times that of the right one, so RASM is 4 in α
+	// even for a signal so weak that its DE is negative
+	for c := range rec.Data {
+		multiply(&rec.Data[c], 0.001)
+	}
+	features, err = GetAsymmetryForRecording(rec, 1000, 500)
+	assert.Nil(t, err)
+	bands := len(EEGBands)
+	for _, f := range features {
+		for _, v := range f {
+			assert.False(t, math.IsNaN(v) || math.IsInf(v, 0))
+		}
+		for k := 0; k < bands; k++ {
+			assert.True(t, f[bands+k] > 0)
+		}
+		assert.InDelta(t, 4, f[bands+α], 1e-6)
+	}
+	de := GetDifferentialEntropyForRecording(rec, 1000, 500)
+	assert.True(t, de[0][α] < 0)
+
+	_, err = asymmetry.Features([][]float64{{1, 2}}, rec.Channels)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,130 @@
+package emotions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Band is a named eeg frequency band, e.g. α between 8 and 13Hz
+type Band struct {
+	Name          string
+	Low           float64
+	High          float64
+	LowInclusive  bool
+	HighInclusive bool
+}
+
+// Contains checks whether the frequency falls in the band
+func (b Band) Contains(freq float64) bool {
+	if freq < b.Low || (freq == b.Low && !b.LowInclusive) {
+		return false
+	}
+	if freq > b.High || (freq == b.High && !b.HighInclusive) {
+		return false
+	}
+	return true
+}
+
+func (b Band) String() string {
+	open, close := "(", ")"
+	if b.LowInclusive {
+		open = "["
+	}
+	if b.HighInclusive {
+		close = "]"
+	}
+	return fmt.Sprintf("%s%s%g,%g%s", b.Name, open, b.Low, b.High, close)
+}
+
+// BandSet is the list of bands the eeg power is accumulated in
+// The eeg feature vectors have one coordinate per band for every electrode
+type BandSet []Band
+
+// Index returns the index of the first band which contains the frequency or -1
+func (s BandSet) Index(freq float64) int {
+	for i, b := range s {
+		if b.Contains(freq) {
+			return i
+		}
+	}
+	return -1
+}
+
+// IndexOf returns the index of the band with the given name or -1
+func (s BandSet) IndexOf(name string) int {
+	for i, b := range s {
+		if b.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Names returns the names of the bands
+func (s BandSet) Names() []string {
+	names := make([]string, len(s), len(s))
+	for i, b := range s {
+		names[i] = b.Name
+	}
+	return names
+}
+
+func (s BandSet) String() string {
+	bands := make([]string, len(s), len(s))
+	for i, b := range s {
+		bands[i] = b.String()
+	}
+	return strings.Join(bands, " ")
+}
+
+// NewBandSet makes adjacent bands from the names and the edges between them (len(edges) == len(names) + 1)
+// Every band contains its lower edge, the last one also contains its upper edge
+func NewBandSet(names []string, edges []float64) (BandSet, error) {
+	if len(edges) != len(names)+1 {
+		return nil, fmt.Errorf("%d bands need %d edges, got %d", len(names), len(names)+1, len(edges))
+	}
+
+	bands := make(BandSet, len(names), len(names))
+	for i := range names {
+		if edges[i] >= edges[i+1] {
+			return nil, fmt.Errorf("band %s has edges %g >= %g", names[i], edges[i], edges[i+1])
+		}
+		bands[i] = Band{
+			Name:          names[i],
+			Low:           edges[i],
+			High:          edges[i+1],
+			LowInclusive:  true,
+			HighInclusive: i == len(names)-1,
+		}
+	}
+	return bands, nil
+}
+
+func mustBandSet(names []string, edges []float64) BandSet {
+	bands, err := NewBandSet(names, edges)
+	if err != nil {
+		panic(err)
+	}
+	return bands
+}
+
+// ClinicalBands are the five clinical bands δ, θ, α, β and γ
+var ClinicalBands = mustBandSet([]string{"δ", "θ", "α", "β", "γ"}, []float64{0.5, 4, 8, 13, 30, 50})
+
+// SEEDBands are the bands used with the SEED dataset, with gaps between them
+var SEEDBands = BandSet{
+	Band{Name: "δ", Low: 1, High: 3, LowInclusive: true, HighInclusive: true},
+	Band{Name: "θ", Low: 4, High: 7, LowInclusive: true, HighInclusive: true},
+	Band{Name: "α", Low: 8, High: 13, LowInclusive: true, HighInclusive: true},
+	Band{Name: "β", Low: 14, High: 30, LowInclusive: true, HighInclusive: true},
+	Band{Name: "γ", Low: 31, High: 50, LowInclusive: true, HighInclusive: true},
+}
+
+// LegacyBands are the four bands the first eeg models were trained with (without δ)
+var LegacyBands = mustBandSet([]string{"θ", "α", "β", "γ"}, []float64{4, 8, 12, 30, 50})
+
+// EEGBands are the bands used by all the eeg features
+// They default to LegacyBands so the features keep the dimensions (numEl * 4) of the models trained before the band sets
+// Models trained with one band set can only be used with features computed with the same one,
+// so switching to ClinicalBands or SEEDBands means retraining the eeg models with them
+var EEGBands = LegacyBands
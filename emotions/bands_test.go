@@ -0,0 +1,23 @@
+package emotions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBandSet(t *testing.T) {
+	assert.Equal(t, ClinicalBands.IndexOf("α"), ClinicalBands.Index(8))
+	assert.Equal(t, ClinicalBands.IndexOf("θ"), ClinicalBands.Index(7.99))
+	assert.Equal(t, ClinicalBands.IndexOf("γ"), ClinicalBands.Index(50))
+	assert.Equal(t, -1, ClinicalBands.Index(0.1))
+	assert.Equal(t, -1, SEEDBands.Index(3.5))
+
+	_, err := NewBandSet([]string{"α", "β"}, []float64{8, 13})
+	assert.NotNil(t, err)
+
+	// the saved eeg models expect 4 bands per electrode
+	assert.Equal(t, LegacyBands, EEGBands)
+	rec := sineRecording(10.0, DefaultEEGSampleRate, 2, DefaultEEGChannels)
+	assert.Len(t, GetFourierForRecording(rec, 1000, 500)[0], 19*4)
+}
@@ -0,0 +1,346 @@
+package emotions
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readINI reads the sections of a BrainVision header or marker file into section -> key -> value
+// The comments start with ; and the lines before the first section (the identification line) are skipped
+func readINI(filename string) (map[string]map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sections := make(map[string]map[string]string)
+	var section map[string]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		switch {
+		case line == "" || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = make(map[string]string)
+			sections[line[1:len(line)-1]] = section
+		case section != nil:
+			if eq := strings.Index(line, "="); eq != -1 {
+				section[strings.TrimSpace(line[:eq])] = strings.TrimSpace(line[eq+1:])
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// splitBrainVision splits the comma separated fields of a channel or marker, where \1 stands for a comma in a field
+func splitBrainVision(value string) []string {
+	fields := strings.Split(value, ",")
+	for i := range fields {
+		fields[i] = strings.Replace(fields[i], `\1`, ",", -1)
+	}
+	return fields
+}
+
+// brainVisionChannel is the label, the resolution (the physical value of a unit) and the physical unit of a channel
+type brainVisionChannel struct {
+	label      string
+	resolution float64
+	unit       string
+}
+
+func brainVisionChannels(infos map[string]string, n int) ([]brainVisionChannel, error) {
+	channels := make([]brainVisionChannel, n, n)
+	for i := range channels {
+		value, ok := infos[fmt.Sprintf("Ch%d", i+1)]
+		if !ok {
+			return nil, fmt.Errorf("missing channel Ch%d", i+1)
+		}
+
+		fields := splitBrainVision(value)
+		channels[i] = brainVisionChannel{label: fields[0], resolution: 1, unit: DefaultEEGUnit}
+		if len(fields) > 2 && fields[2] != "" {
+			resolution, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid resolution of channel %s: %q", fields[0], fields[2])
+			}
+			channels[i].resolution = resolution
+		}
+		if len(fields) > 3 && fields[3] != "" {
+			channels[i].unit = fields[3]
+		}
+	}
+	return channels, nil
+}
+
+// readBrainVisionBinary reads samples x channels (multiplexed) or channels x samples (vectorized) binary data into channels x samples
+func readBrainVisionBinary(reader io.Reader, format string, channels int, multiplexed bool) ([][]float64, error) {
+	var values []float64
+	buffered := bufio.NewReader(reader)
+	for {
+		var v float64
+		var err error
+		switch format {
+		case "INT_16":
+			var x int16
+			err = binary.Read(buffered, binary.LittleEndian, &x)
+			v = float64(x)
+		case "INT_32":
+			var x int32
+			err = binary.Read(buffered, binary.LittleEndian, &x)
+			v = float64(x)
+		case "IEEE_FLOAT_32":
+			var x float32
+			err = binary.Read(buffered, binary.LittleEndian, &x)
+			v = float64(x)
+		default:
+			return nil, fmt.Errorf("unknown binary format %s", format)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	if len(values)%channels != 0 {
+		return nil, fmt.Errorf("%d values for %d channels", len(values), channels)
+	}
+
+	samples := len(values) / channels
+	data := make([][]float64, channels, channels)
+	for c := range data {
+		data[c] = make([]float64, samples, samples)
+		for t := range data[c] {
+			if multiplexed {
+				data[c][t] = values[t*channels+c]
+			} else {
+				data[c][t] = values[c*samples+t]
+			}
+		}
+	}
+	return data, nil
+}
+
+// readBrainVisionASCII reads a line per sample (multiplexed) or per channel (vectorized) into channels x samples
+func readBrainVisionASCII(reader io.Reader, infos map[string]string, channels int, multiplexed bool) ([][]float64, error) {
+	skipLines, _ := strconv.Atoi(infos["SkipLines"])
+	skipColumns, _ := strconv.Atoi(infos["SkipColumns"])
+	comma := infos["DecimalSymbol"] == ","
+
+	var rows [][]float64
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if line <= skipLines || strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if skipColumns > len(fields) {
+			return nil, fmt.Errorf("line %d has %d columns", line, len(fields))
+		}
+
+		row := make([]float64, 0, len(fields)-skipColumns)
+		for _, f := range fields[skipColumns:] {
+			if comma {
+				f = strings.Replace(f, ",", ".", 1)
+			}
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			row = append(row, v)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if multiplexed {
+		for i, r := range rows {
+			if len(r) != channels {
+				return nil, fmt.Errorf("sample %d has %d channels instead of %d", i, len(r), channels)
+			}
+		}
+		return transposed(rows), nil
+	}
+
+	if len(rows) != channels {
+		return nil, fmt.Errorf("%d lines for %d channels", len(rows), channels)
+	}
+	return rows, nil
+}
+
+// parseBrainVisionDate parses the yyyymmddhhmmssuuuuuu date of a New Segment marker
+func parseBrainVisionDate(date string) (time.Time, error) {
+	if len(date) != 20 {
+		return time.Time{}, fmt.Errorf("invalid date %q", date)
+	}
+	start, err := time.Parse("20060102150405", date[:14])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", date)
+	}
+	micro, err := strconv.Atoi(date[14:])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", date)
+	}
+	return start.Add(time.Duration(micro) * time.Microsecond), nil
+}
+
+// readBrainVisionMarkers reads the markers of a .vmrk file as events relative to the start of the recording
+// The description of a marker is its label (or its type if it has no description) and the start is taken from the New Segment
+func readBrainVisionMarkers(filename string, sampleRate int) (time.Time, []Event, error) {
+	sections, err := readINI(filename)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	type marker struct {
+		label    string
+		position int
+		size     int
+	}
+
+	var start time.Time
+	var markers []marker
+	infos := sections["Marker Infos"]
+	for i := 1; ; i++ {
+		value, ok := infos[fmt.Sprintf("Mk%d", i)]
+		if !ok {
+			break
+		}
+
+		fields := splitBrainVision(value)
+		if len(fields) < 3 {
+			return time.Time{}, nil, fmt.Errorf("%s: invalid marker Mk%d=%s", filename, i, value)
+		}
+		position, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return time.Time{}, nil, fmt.Errorf("%s: invalid position of marker Mk%d=%s", filename, i, value)
+		}
+		size := 1
+		if len(fields) > 3 && fields[3] != "" {
+			if size, err = strconv.Atoi(fields[3]); err != nil {
+				return time.Time{}, nil, fmt.Errorf("%s: invalid size of marker Mk%d=%s", filename, i, value)
+			}
+		}
+
+		if fields[0] == "New Segment" && len(fields) > 5 && fields[5] != "" && start.IsZero() {
+			if start, err = parseBrainVisionDate(fields[5]); err != nil {
+				return time.Time{}, nil, fmt.Errorf("%s: %s", filename, err)
+			}
+			start = start.Add(-time.Duration(float64(position-1) / float64(sampleRate) * float64(time.Second)))
+			continue
+		}
+
+		label := fields[1]
+		if label == "" {
+			label = fields[0]
+		}
+		markers = append(markers, marker{label: label, position: position, size: size})
+	}
+
+	events := make([]Event, len(markers), len(markers))
+	for i, m := range markers {
+		events[i] = Event{
+			Onset: start.Add(seconds(float64(m.position-1) / float64(sampleRate))),
+			Label: m.label,
+		}
+		if m.size > 1 {
+			events[i].Duration = seconds(float64(m.size) / float64(sampleRate))
+		}
+	}
+	return start, events, nil
+}
+
+// ReadBrainVision reads the recording described by a BrainVision header (.vhdr) in the physical units of the channels
+// together with the markers of its .vmrk as events
+func ReadBrainVision(filename string) (EEGRecording, []Event, error) {
+	sections, err := readINI(filename)
+	if err != nil {
+		return EEGRecording{}, nil, err
+	}
+
+	common := sections["Common Infos"]
+	n, err := strconv.Atoi(common["NumberOfChannels"])
+	if err != nil || n <= 0 {
+		return EEGRecording{}, nil, fmt.Errorf("%s: invalid number of channels %q", filename, common["NumberOfChannels"])
+	}
+	interval, err := strconv.ParseFloat(common["SamplingInterval"], 64)
+	if err != nil || interval <= 0 {
+		return EEGRecording{}, nil, fmt.Errorf("%s: invalid sampling interval %q", filename, common["SamplingInterval"])
+	}
+	rate := 1e6 / interval
+	if math.Abs(rate-math.Round(rate)) > 1e-6 {
+		return EEGRecording{}, nil, fmt.Errorf("%s: sample rate %g is not whole", filename, rate)
+	}
+	sampleRate := int(math.Round(rate))
+
+	channels, err := brainVisionChannels(sections["Channel Infos"], n)
+	if err != nil {
+		return EEGRecording{}, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	dir := filepath.Dir(filename)
+	file, err := os.Open(filepath.Join(dir, common["DataFile"]))
+	if err != nil {
+		return EEGRecording{}, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	defer file.Close()
+
+	multiplexed := !strings.EqualFold(common["DataOrientation"], "VECTORIZED")
+	var data [][]float64
+	switch strings.ToUpper(common["DataFormat"]) {
+	case "BINARY", "":
+		data, err = readBrainVisionBinary(file, strings.ToUpper(sections["Binary Infos"]["BinaryFormat"]), n, multiplexed)
+	case "ASCII":
+		data, err = readBrainVisionASCII(file, sections["ASCII Infos"], n, multiplexed)
+	default:
+		err = fmt.Errorf("unknown data format %s", common["DataFormat"])
+	}
+	if err != nil {
+		return EEGRecording{}, nil, fmt.Errorf("%s: %s", common["DataFile"], err)
+	}
+
+	labels := make([]string, n, n)
+	units := make([]string, n, n)
+	for c, channel := range channels {
+		labels[c] = channel.label
+		units[c] = channel.unit
+		multiply(&data[c], channel.resolution)
+	}
+
+	rec, err := NewEEGRecording(data, sampleRate, labels, units)
+	if err != nil {
+		return EEGRecording{}, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	var events []Event
+	if marker := common["MarkerFile"]; marker != "" {
+		rec.Start, events, err = readBrainVisionMarkers(filepath.Join(dir, marker), sampleRate)
+		if err != nil {
+			return EEGRecording{}, nil, err
+		}
+	}
+	return rec, events, nil
+}
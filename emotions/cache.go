@@ -0,0 +1,275 @@
+package emotions
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheExt = ".features"
+
+// FeatureCache keeps extracted features on disk so repeated experiments (e.g. cross validation folds)
+// don't compute them again for the same files
+// An entry is keyed by the sha256 of the content of the input file and the full feature configuration,
+// so changing either the file or the configuration never returns stale features.
+// Entries are written to a temporary file and renamed, so concurrent readers and writers
+// (goroutines or processes) sharing the directory always see whole entries.
+// Note that the cached features keep the dither noise of the run that computed them.
+type FeatureCache struct {
+	dir      string
+	maxBytes int64
+	mutex    sync.Mutex
+}
+
+// NewFeatureCache returns a cache in the given directory, creating it if needed
+// When maxBytes is positive the least recently used entries are removed once the cache grows beyond it
+func NewFeatureCache(dir string, maxBytes int64) (*FeatureCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FeatureCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}, nil
+}
+
+func hashFile(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashString(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])
+}
+
+// path returns the file in which the features of filename with the given configuration are stored
+// all the entries for the same content share the same prefix
+func (c *FeatureCache) path(filename string, config string) (string, error) {
+	fileHash, err := hashFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(c.dir, fileHash+"-"+hashString(config)+cacheExt), nil
+}
+
+// Get returns the cached features for the file and configuration
+// and the path of the entry (which can be used with Put on a miss)
+func (c *FeatureCache) Get(filename string, config string) ([][]float64, string, bool) {
+	path, err := c.path(filename, config)
+	if err != nil {
+		return nil, "", false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, path, false
+	}
+	defer file.Close()
+
+	var features [][]float64
+	if err := gob.NewDecoder(file).Decode(&features); err != nil {
+		return nil, path, false
+	}
+
+	// the modification time marks the last use for the eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return features, path, true
+}
+
+// Put stores the features in the entry with the given path
+func (c *FeatureCache) Put(path string, features [][]float64) error {
+	tmp, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(tmp).Encode(features)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return c.evict()
+}
+
+// Features returns the cached features for the file and configuration, computing and storing them on a miss
+func (c *FeatureCache) Features(filename string, config string, compute func() [][]float64) [][]float64 {
+	features, path, ok := c.Get(filename, config)
+	if ok {
+		return features
+	}
+
+	features = compute()
+	if path == "" {
+		return features
+	}
+
+	if err := c.Put(path, features); err != nil {
+		fmt.Fprintf(os.Stderr, "could not cache features for %s: %s\n", filename, err)
+	}
+	return features
+}
+
+// Invalidate removes all the entries (for every configuration) for the current content of the file
+func (c *FeatureCache) Invalidate(filename string) error {
+	fileHash, err := hashFile(filename)
+	if err != nil {
+		return err
+	}
+
+	entries, err := filepath.Glob(filepath.Join(c.dir, fileHash+"-*"+cacheExt))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := os.Remove(entry); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear removes every entry in the cache
+func (c *FeatureCache) Clear() error {
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), cacheExt) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// evict removes the least recently used entries until the cache fits in maxBytes
+func (c *FeatureCache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]os.FileInfo, 0, len(infos))
+	var size int64
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), cacheExt) {
+			entries = append(entries, info)
+			size += info.Size()
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+
+	for i := 0; i < len(entries) && size > c.maxBytes; i++ {
+		// another process may have removed it already
+		if err := os.Remove(filepath.Join(c.dir, entries[i].Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= entries[i].Size()
+	}
+
+	return nil
+}
+
+var featureCache *FeatureCache
+
+// EnableFeatureCache makes all the feature entry points (ReadSpeechFeatures, GetFourierForFile...)
+// use an on-disk cache in dir limited to maxBytes (no limit if maxBytes <= 0)
+// It should be called before any features are extracted
+func EnableFeatureCache(dir string, maxBytes int64) error {
+	cache, err := NewFeatureCache(dir, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	featureCache = cache
+	return nil
+}
+
+// DisableFeatureCache makes the feature entry points compute the features every time
+func DisableFeatureCache() {
+	featureCache = nil
+}
+
+// cachedFeatures uses the feature cache if it is enabled
+// the config should describe everything the computed features depend on besides the content of the file
+func cachedFeatures(filename string, config string, compute func() [][]float64) [][]float64 {
+	if featureCache == nil {
+		return compute()
+	}
+
+	return featureCache.Features(filename, config, compute)
+}
+
+// cachedFeaturesOrError is cachedFeatures for a computation which can fail, whose errors are not cached
+func cachedFeaturesOrError(filename string, config string, compute func() ([][]float64, error)) ([][]float64, error) {
+	if featureCache == nil {
+		return compute()
+	}
+
+	features, path, ok := featureCache.Get(filename, config)
+	if ok {
+		return features, nil
+	}
+
+	features, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := featureCache.Put(path, features); err != nil {
+			fmt.Fprintf(os.Stderr, "could not cache features for %s: %s\n", filename, err)
+		}
+	}
+	return features, nil
+}
+
+// featureConfig describes the settings shared by all the features in a cache key
+func featureConfig(format string, args ...interface{}) string {
+	return fmt.Sprintf("%s frame=%d step=%d logFloor=%g policy=%s", fmt.Sprintf(format, args...), FRAME_IN_MS, STEP_IN_MS, LogFloor, FeaturePolicy)
+}
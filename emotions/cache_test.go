@@ -0,0 +1,56 @@
+package emotions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "features")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.txt")
+	assert.Nil(t, ioutil.WriteFile(input, []byte("1 2 3"), 0644))
+
+	cache, err := NewFeatureCache(filepath.Join(dir, "cache"), 0)
+	assert.Nil(t, err)
+
+	computed := 0
+	compute := func() [][]float64 {
+		computed++
+		return [][]float64{[]float64{1.0, 2.0}, []float64{3.0, 4.0}}
+	}
+
+	first := cache.Features(input, "a", compute)
+	second := cache.Features(input, "a", compute)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, computed)
+
+	cache.Features(input, "b", compute)
+	assert.Equal(t, 2, computed)
+
+	// a different content is a different key
+	assert.Nil(t, ioutil.WriteFile(input, []byte("1 2 4"), 0644))
+	cache.Features(input, "a", compute)
+	assert.Equal(t, 3, computed)
+
+	assert.Nil(t, cache.Invalidate(input))
+	cache.Features(input, "a", compute)
+	assert.Equal(t, 4, computed)
+
+	assert.Nil(t, cache.Clear())
+	entries, _ := filepath.Glob(filepath.Join(dir, "cache", "*"+cacheExt))
+	assert.Equal(t, 0, len(entries))
+
+	// a cache smaller than a single entry keeps nothing
+	small, err := NewFeatureCache(filepath.Join(dir, "small"), 1)
+	assert.Nil(t, err)
+	small.Features(input, "a", compute)
+	entries, _ = filepath.Glob(filepath.Join(dir, "small", "*"+cacheExt))
+	assert.Equal(t, 0, len(entries))
+}
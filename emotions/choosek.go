@@ -0,0 +1,401 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+func euclidean(x []float64, y []float64) float64 {
+	return math.Sqrt(euclidianDistance(x, y, nil))
+}
+
+// clusterCentroids returns the mean and the size of every cluster
+func clusterCentroids(data [][]float64, labels []int, k int) ([][]float64, []int) {
+	centroids := make([][]float64, k, k)
+	for c := range centroids {
+		centroids[c] = make([]float64, len(data[0]), len(data[0]))
+	}
+	sizes := make([]int, k, k)
+	for i, x := range data {
+		sizes[labels[i]]++
+		add(&centroids[labels[i]], x)
+	}
+	for c := range centroids {
+		if sizes[c] > 0 {
+			divide(&centroids[c], float64(sizes[c]))
+		}
+	}
+	return centroids, sizes
+}
+
+// numClusters returns the largest label + 1
+func numClusters(labels []int) int {
+	k := 0
+	for _, l := range labels {
+		k = Max(k, l+1)
+	}
+	return k
+}
+
+// withinSumOfSquares returns the sum of the squared distances of the points to the centroids of their clusters
+func withinSumOfSquares(data [][]float64, labels []int) float64 {
+	centroids, _ := clusterCentroids(data, labels, numClusters(labels))
+	var w float64
+	for i, x := range data {
+		w += euclidianDistance(x, centroids[labels[i]], nil)
+	}
+	return w
+}
+
+// Silhouette returns the mean silhouette (b - a) / max(a, b) of the points, where a is the mean distance to the rest of the cluster
+// and b the mean distance to the closest other cluster, from -1 (wrong clusters) to 1 (well separated ones)
+// It is quadratic in the number of points, so if sample is positive only that many random points are scored
+// chosen by random, or by a source with a fixed seed if it is nil
+func Silhouette(data [][]float64, labels []int, sample int, random *rand.Rand) float64 {
+	k := numClusters(labels)
+	if k < 2 {
+		return 0
+	}
+	_, sizes := clusterCentroids(data, labels, k)
+
+	points := make([]int, len(data), len(data))
+	for i := range points {
+		points[i] = i
+	}
+	if sample > 0 && sample < len(data) {
+		if random == nil {
+			random = rand.New(rand.NewSource(1))
+		}
+		points = random.Perm(len(data))[:sample]
+	}
+
+	var sum float64
+	for _, i := range points {
+		// a point alone in its cluster has a silhouette of 0
+		if sizes[labels[i]] < 2 {
+			continue
+		}
+
+		distances := make([]float64, k, k)
+		for j, y := range data {
+			if j != i {
+				distances[labels[j]] += euclidean(data[i], y)
+			}
+		}
+
+		a := distances[labels[i]] / float64(sizes[labels[i]]-1)
+		b := math.Inf(1)
+		for c := range distances {
+			if c != labels[i] && sizes[c] > 0 {
+				b = math.Min(b, distances[c]/float64(sizes[c]))
+			}
+		}
+		if max := math.Max(a, b); max > 0 {
+			sum += (b - a) / max
+		}
+	}
+	return sum / float64(len(points))
+}
+
+// DaviesBouldin returns the mean over the clusters of the largest (s_i + s_j) / d(c_i, c_j)
+// where s is the mean distance of the points to their centroid, lower is better
+func DaviesBouldin(data [][]float64, labels []int) float64 {
+	k := numClusters(labels)
+	if k < 2 {
+		return 0
+	}
+	centroids, sizes := clusterCentroids(data, labels, k)
+
+	scatter := make([]float64, k, k)
+	for i, x := range data {
+		scatter[labels[i]] += euclidean(x, centroids[labels[i]])
+	}
+	for c := range scatter {
+		if sizes[c] > 0 {
+			scatter[c] /= float64(sizes[c])
+		}
+	}
+
+	var sum float64
+	clusters := 0
+	for i := range centroids {
+		if sizes[i] == 0 {
+			continue
+		}
+		clusters++
+
+		worst := 0.0
+		for j := range centroids {
+			if j == i || sizes[j] == 0 {
+				continue
+			}
+			if d := euclidean(centroids[i], centroids[j]); d > 0 {
+				worst = math.Max(worst, (scatter[i]+scatter[j])/d)
+			} else {
+				worst = math.Inf(1)
+			}
+		}
+		sum += worst
+	}
+	return sum / float64(clusters)
+}
+
+// CalinskiHarabasz returns the ratio of the dispersion between the clusters and the one within them
+// each divided by its degrees of freedom, higher is better
+func CalinskiHarabasz(data [][]float64, labels []int) float64 {
+	k := numClusters(labels)
+	if k < 2 || len(data) <= k {
+		return 0
+	}
+	centroids, sizes := clusterCentroids(data, labels, k)
+	mean := Average(data)
+
+	var between float64
+	for c, centroid := range centroids {
+		between += float64(sizes[c]) * euclidianDistance(centroid, mean, nil)
+	}
+	within := withinSumOfSquares(data, labels)
+	if within == 0 {
+		return math.Inf(1)
+	}
+
+	return (between / float64(k-1)) / (within / float64(len(data)-k))
+}
+
+// clusterLabels returns the cluster of every point in the order of the data
+func clusterLabels(result KMeansResult) []int {
+	labels := make([]int, len(result.Clustered), len(result.Clustered))
+	for i, m := range result.Clustered {
+		labels[i] = int(m.clusterID)
+	}
+	return labels
+}
+
+// GapStatistic compares log W_k, the log of the within cluster sum of squares, with its mean over references
+// uniform in the bounding box of the data (Tibshirani et al.) and returns the gap and the standard error of the references
+func GapStatistic(data [][]float64, k int, references int, options KMeansOptions) (float64, float64) {
+	options.Rand = options.random()
+	w := math.Log(withinSumOfSquares(data, clusterLabels(KMeansWithOptions(data, k, options))))
+
+	low := append([]float64{}, data[0]...)
+	high := append([]float64{}, data[0]...)
+	for _, x := range data {
+		for j := range x {
+			low[j] = math.Min(low[j], x[j])
+			high[j] = math.Max(high[j], x[j])
+		}
+	}
+
+	// the uniform references have nothing to weigh
+	options.Weights = nil
+	logs := make([]float64, references, references)
+	reference := make([][]float64, len(data), len(data))
+	for b := range logs {
+		for i := range reference {
+			reference[i] = make([]float64, len(low), len(low))
+			for j := range low {
+				reference[i][j] = low[j] + options.Rand.Float64()*(high[j]-low[j])
+			}
+		}
+		logs[b] = math.Log(withinSumOfSquares(reference, clusterLabels(KMeansWithOptions(reference, k, options))))
+	}
+
+	stats := statsOfValues(logs)
+
+	return stats.Mean()[0] - w, stats.StandardDeviation()[0] * math.Sqrt(1+1/float64(references))
+}
+
+// Elbow returns the k after which the rss stops falling fast, the point of the curve farthest from the line
+// between its ends once both axes are scaled to [0, 1]
+func Elbow(ks []int, rss []float64) int {
+	if len(ks) < 3 {
+		return ks[0]
+	}
+
+	last := len(ks) - 1
+	x := func(i int) float64 { return float64(ks[i]-ks[0]) / float64(ks[last]-ks[0]) }
+	y := func(i int) float64 {
+		if rss[0] == rss[last] {
+			return 0
+		}
+		return (rss[i] - rss[last]) / (rss[0] - rss[last])
+	}
+
+	// the scaled curve goes from (0, 1) to (1, 0), so the distance to the line is proportional to 1 - x - y
+	best := 0
+	max := math.Inf(-1)
+	for i := range ks {
+		if d := 1 - x(i) - y(i); d > max {
+			max = d
+			best = i
+		}
+	}
+	return ks[best]
+}
+
+// KCriterion is the score the number of clusters is chosen by
+type KCriterion int
+
+const (
+	// CriterionSilhouette picks the k with the highest silhouette
+	CriterionSilhouette KCriterion = iota
+	// CriterionDaviesBouldin picks the k with the lowest Davies-Bouldin index
+	CriterionDaviesBouldin
+	// CriterionCalinskiHarabasz picks the k with the highest Calinski-Harabasz index
+	CriterionCalinskiHarabasz
+	// CriterionGap picks the smallest k with gap(k) >= gap(k+1) - s(k+1)
+	CriterionGap
+	// CriterionElbow picks the elbow of the rss
+	CriterionElbow
+)
+
+func (c KCriterion) String() string {
+	switch c {
+	case CriterionSilhouette:
+		return "silhouette"
+	case CriterionDaviesBouldin:
+		return "davies-bouldin"
+	case CriterionCalinskiHarabasz:
+		return "calinski-harabasz"
+	case CriterionGap:
+		return "gap"
+	case CriterionElbow:
+		return "elbow"
+	default:
+		return fmt.Sprintf("KCriterion(%d)", int(c))
+	}
+}
+
+// KScores are the scores of the clustering with K clusters
+type KScores struct {
+	K int
+	// RSS is the euclidean within cluster sum of squares, which the elbow is looked for in
+	RSS              float64
+	Silhouette       float64
+	DaviesBouldin    float64
+	CalinskiHarabasz float64
+	// Gap and GapError are computed only for CriterionGap, since every reference is another k-means, and are 0 otherwise
+	Gap      float64
+	GapError float64
+}
+
+// KChoice is the k chosen by ChooseK with the scores of all the tried ones
+type KChoice struct {
+	K         int
+	Criterion KCriterion
+	Scores    []KScores
+}
+
+// ChooseKOptions are the parameters of ChooseKWithOptions
+type ChooseKOptions struct {
+	KMeans KMeansOptions
+	// SilhouetteSample is the number of points the silhouette is computed for, all of them if it is not positive
+	SilhouetteSample int
+	// GapReferences is the number of uniform references of the gap statistic
+	GapReferences int
+}
+
+// DefaultChooseKOptions returns k-means++ with 5 restarts, a silhouette of 1000 points and 10 references for the gap
+func DefaultChooseKOptions() ChooseKOptions {
+	options := ChooseKOptions{
+		KMeans:           DefaultKMeansOptions(),
+		SilhouetteSample: 1000,
+		GapReferences:    10,
+	}
+	options.KMeans.Init = InitKMeansPP
+	options.KMeans.NInit = 5
+	return options
+}
+
+// ChooseK clusters the data with every k in the increasing kRange and picks one of them by the criterion
+// The scores use euclidean distances, so the coordinates of the data should have comparable scales
+func ChooseK(data [][]float64, kRange []int, criterion KCriterion) (KChoice, error) {
+	return ChooseKWithOptions(data, kRange, criterion, DefaultChooseKOptions())
+}
+
+// ChooseKWithOptions is ChooseK with the given parameters
+func ChooseKWithOptions(data [][]float64, kRange []int, criterion KCriterion, options ChooseKOptions) (KChoice, error) {
+	if len(kRange) == 0 {
+		return KChoice{}, fmt.Errorf("no k to choose from")
+	}
+	for i, k := range kRange {
+		if k < 1 || k > len(data) {
+			return KChoice{}, fmt.Errorf("%d clusters for %d points", k, len(data))
+		}
+		if i > 0 && k <= kRange[i-1] {
+			return KChoice{}, fmt.Errorf("the k range %v is not increasing", kRange)
+		}
+	}
+	switch criterion {
+	case CriterionSilhouette, CriterionDaviesBouldin, CriterionCalinskiHarabasz:
+		if kRange[len(kRange)-1] < 2 {
+			return KChoice{}, fmt.Errorf("%s needs at least 2 clusters", criterion)
+		}
+	case CriterionGap, CriterionElbow:
+	default:
+		return KChoice{}, fmt.Errorf("unknown criterion %s", criterion)
+	}
+
+	options.KMeans.Rand = options.KMeans.random()
+	choice := KChoice{Criterion: criterion, Scores: make([]KScores, len(kRange), len(kRange))}
+	for i, k := range kRange {
+		result := KMeansWithOptions(data, k, options.KMeans)
+		labels := clusterLabels(result)
+		choice.Scores[i] = KScores{
+			K:                k,
+			RSS:              withinSumOfSquares(data, labels),
+			Silhouette:       Silhouette(data, labels, options.SilhouetteSample, options.KMeans.Rand),
+			DaviesBouldin:    DaviesBouldin(data, labels),
+			CalinskiHarabasz: CalinskiHarabasz(data, labels),
+		}
+		if criterion == CriterionGap {
+			choice.Scores[i].Gap, choice.Scores[i].GapError = GapStatistic(data, k, options.GapReferences, options.KMeans)
+		}
+	}
+
+	scores := choice.Scores
+	best := -1
+	better := func(i int, score func(KScores) float64, lower bool) {
+		if scores[i].K < 2 {
+			return
+		}
+		if best == -1 || (lower && score(scores[i]) < score(scores[best])) || (!lower && score(scores[i]) > score(scores[best])) {
+			best = i
+		}
+	}
+	switch criterion {
+	case CriterionSilhouette:
+		for i := range scores {
+			better(i, func(s KScores) float64 { return s.Silhouette }, false)
+		}
+	case CriterionDaviesBouldin:
+		for i := range scores {
+			better(i, func(s KScores) float64 { return s.DaviesBouldin }, true)
+		}
+	case CriterionCalinskiHarabasz:
+		for i := range scores {
+			better(i, func(s KScores) float64 { return s.CalinskiHarabasz }, false)
+		}
+	case CriterionGap:
+		for i := 0; i+1 < len(scores) && best == -1; i++ {
+			if scores[i].Gap >= scores[i+1].Gap-scores[i+1].GapError {
+				best = i
+			}
+		}
+		if best == -1 {
+			best = len(scores) - 1
+		}
+	case CriterionElbow:
+		ks := make([]int, len(scores), len(scores))
+		rss := make([]float64, len(scores), len(scores))
+		for i, s := range scores {
+			ks[i], rss[i] = s.K, s.RSS
+		}
+		choice.K = Elbow(ks, rss)
+		return choice, nil
+	}
+
+	choice.K = scores[best].K
+	return choice, nil
+}
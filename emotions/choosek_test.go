@@ -0,0 +1,53 @@
+package emotions
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterScores(t *testing.T) {
+	data := [][]float64{{0, 0}, {0, 1}, {10, 0}, {10, 1}}
+	good := []int{0, 0, 1, 1}
+	bad := []int{0, 1, 0, 1}
+
+	assert.InDelta(t, 1-1/((10+10.0499)/2), Silhouette(data, good, 0, nil), 1e-4)
+	assert.True(t, Silhouette(data, bad, 0, nil) < 0)
+	assert.InDelta(t, 0.1, DaviesBouldin(data, good), 1e-9)
+	assert.True(t, DaviesBouldin(data, bad) > DaviesBouldin(data, good))
+	// between = 4 * 25 over 1 degree, within = 4 * 0.25 over 2 degrees
+	assert.InDelta(t, 200, CalinskiHarabasz(data, good), 1e-9)
+
+	assert.Equal(t, 3, Elbow([]int{1, 2, 3, 4, 5, 6}, []float64{100, 60, 12, 10, 9, 8}))
+}
+
+func TestChooseK(t *testing.T) {
+	random := rand.New(rand.NewSource(12))
+	centres := [][]float64{{0, 0}, {30, 0}, {15, 30}}
+	points := make([][]float64, 240, 240)
+	for i := range points {
+		points[i] = []float64{centres[i%3][0] + random.NormFloat64(), centres[i%3][1] + random.NormFloat64()}
+	}
+
+	options := DefaultChooseKOptions()
+	options.KMeans.Rand = rand.New(rand.NewSource(3))
+	options.GapReferences = 5
+	for _, criterion := range []KCriterion{CriterionSilhouette, CriterionDaviesBouldin, CriterionCalinskiHarabasz, CriterionGap, CriterionElbow} {
+		choice, err := ChooseKWithOptions(points, []int{1, 2, 3, 4, 5, 6}, criterion, options)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, choice.K, criterion.String())
+		assert.Len(t, choice.Scores, 6)
+		// the references of the gap are clustered only when it is the criterion
+		assert.Equal(t, criterion == CriterionGap, choice.Scores[2].Gap != 0, criterion.String())
+	}
+
+	// a sampled silhouette without a source of randomness uses a seeded one
+	labels := clusterLabels(KMeansWithOptions(points, 3, options.KMeans))
+	assert.Equal(t, Silhouette(points, labels, 50, nil), Silhouette(points, labels, 50, nil))
+
+	_, err := ChooseK(points, []int{3, 2}, CriterionGap)
+	assert.Error(t, err)
+	_, err = ChooseK(points, []int{1}, CriterionSilhouette)
+	assert.Error(t, err)
+}
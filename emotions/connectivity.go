@@ -0,0 +1,159 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConnectivityMeasure is a measure of the synchrony of two channels in a band
+type ConnectivityMeasure int
+
+const (
+	// Coherence is the magnitude-squared coherence |E[x y*]|² / (E[|x|²] E[|y|²]) of the analytic signals
+	Coherence ConnectivityMeasure = iota
+	// PLV is the phase locking value |E[exp(i(φx - φy))]|
+	PLV
+	// WPLI is the weighted phase-lag index |E[Im(x y*)]| / E[|Im(x y*)|], which ignores zero-lag volume conduction
+	WPLI
+)
+
+func (m ConnectivityMeasure) String() string {
+	switch m {
+	case Coherence:
+		return "coherence"
+	case PLV:
+		return "plv"
+	case WPLI:
+		return "wpli"
+	default:
+		return fmt.Sprintf("ConnectivityMeasure(%d)", int(m))
+	}
+}
+
+// AllPairs returns every pair of different channels (i < j)
+func AllPairs(channels []string) [][2]string {
+	var pairs [][2]string
+	for i := range channels {
+		for j := i + 1; j < len(channels); j++ {
+			pairs = append(pairs, [2]string{channels[i], channels[j]})
+		}
+	}
+	return pairs
+}
+
+// ConnectivityNames returns the name of every coordinate of the connectivity features, e.g. "plv Fp1-Fp2 α"
+func ConnectivityNames(measure ConnectivityMeasure, pairs [][2]string) []string {
+	names := make([]string, 0, len(pairs)*len(EEGBands))
+	for _, p := range pairs {
+		for _, band := range EEGBands.Names() {
+			names = append(names, fmt.Sprintf("%s %s-%s %s", measure, p[0], p[1], band))
+		}
+	}
+	return names
+}
+
+// analyticSignal returns the analytic signal of the part of the frame in the band, from its fourier coefficients (see FftReal)
+// The coefficients outside the band are dropped, the positive frequencies are doubled and the negative ones zeroed
+func analyticSignal(coefficients []Complex, sampleRate int, band Band) []Complex {
+	n := 2 * (len(coefficients) - 1)
+	spectrum := make([]Complex, n, n)
+	for k, c := range coefficients {
+		if !band.Contains(IndToFreq(k, sampleRate, len(coefficients))) {
+			continue
+		}
+		if k == 0 || k == n/2 {
+			spectrum[k] = c
+		} else {
+			spectrum[k] = Complex{Re: 2 * c.Re, Im: 2 * c.Im}
+		}
+	}
+	return Ifft(spectrum)
+}
+
+// synchrony returns the measure of the two analytic signals over their first samples
+func synchrony(x []Complex, y []Complex, samples int, measure ConnectivityMeasure) float64 {
+	var cross, phases Complex
+	var xx, yy, imaginary, absImaginary float64
+	for t := 0; t < samples; t++ {
+		c := dot(x[t], y[t].conjugate())
+		cross.added(c)
+		xx += Power(x[t])
+		yy += Power(y[t])
+		imaginary += c.Im
+		absImaginary += math.Abs(c.Im)
+		if m := Magnitude(c); m > 0 {
+			phases.added(c.divide(m))
+		}
+	}
+
+	switch measure {
+	case Coherence:
+		if xx == 0 || yy == 0 {
+			return 0
+		}
+		return Power(cross) / (xx * yy)
+	case PLV:
+		return Magnitude(phases) / float64(samples)
+	case WPLI:
+		if absImaginary == 0 {
+			return 0
+		}
+		return math.Abs(imaginary) / absImaginary
+	default:
+		panic(fmt.Sprintf("unknown connectivity measure %s", measure))
+	}
+}
+
+// GetConnectivityForRecording returns the measure between the channels of every pair (all of them if pairs is nil)
+// in every band for each frame, in the layout of getFourier with pairs instead of electrodes:
+// numFrames x (numPairs * len(EEGBands)), see ConnectivityNames
+func GetConnectivityForRecording(rec EEGRecording, frameLen int, frameStep int, measure ConnectivityMeasure, pairs [][2]string) ([][]float64, error) {
+	if pairs == nil {
+		pairs = AllPairs(rec.Channels)
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("no channel pairs in %v", rec.Channels)
+	}
+
+	indices := make([][2]int, len(pairs), len(pairs))
+	used := make(map[int]struct{})
+	for i, p := range pairs {
+		indices[i] = [2]int{findChannel(rec, p[0]), findChannel(rec, p[1])}
+		if indices[i][0] == -1 || indices[i][1] == -1 {
+			return nil, fmt.Errorf("missing channels for %s-%s in %v", p[0], p[1], rec.Channels)
+		}
+		used[indices[i][0]] = struct{}{}
+		used[indices[i][1]] = struct{}{}
+	}
+	if !hasFrames(rec, frameLen, frameStep) {
+		return nil, fmt.Errorf("the recording of %s is too short for a frame of %dms", rec.Duration(), frameLen)
+	}
+
+	// the padding of the frames to a power of two is left out of the averages
+	samples := int((float64(frameLen) / 1000.0) * float64(rec.SampleRate))
+
+	// analytic is channel x frame x band x samples
+	analytic := make(map[int][][][]Complex, len(used))
+	for c := range used {
+		frames := cutElectrodeIntoFrames(rec.Data[c], rec.SampleRate, frameLen, frameStep, false)
+		analytic[c] = make([][][]Complex, len(frames), len(frames))
+		for f, coefficients := range fourierElectrode(frames) {
+			analytic[c][f] = make([][]Complex, len(EEGBands), len(EEGBands))
+			for b, band := range EEGBands {
+				analytic[c][f][b] = analyticSignal(coefficients, rec.SampleRate, band)
+			}
+		}
+	}
+
+	numFrames := len(analytic[indices[0][0]])
+	features := make([][]float64, numFrames, numFrames)
+	for f := range features {
+		features[f] = make([]float64, 0, len(pairs)*len(EEGBands))
+		for _, p := range indices {
+			for b := range EEGBands {
+				features[f] = append(features[f], synchrony(analytic[p[0]][f][b], analytic[p[1]][f][b], samples, measure))
+			}
+		}
+	}
+	return features, nil
+}
@@ -0,0 +1,47 @@
+package emotions
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectivity(t *testing.T) {
+	random := rand.New(rand.NewSource(5))
+	samples := 2000
+	data := make([][]float64, 3, 3)
+	for c := range data {
+		data[c] = make([]float64, samples, samples)
+	}
+	for i := 0; i < samples; i++ {
+		x := 2 * math.Pi * 10 * float64(i) / 500
+		// O1 lags Fp1 by a quarter of a period and Cz is noise
+		data[0][i] = math.Sin(x)
+		data[1][i] = math.Sin(x-math.Pi/2) + 0.1*random.NormFloat64()
+		data[2][i] = random.NormFloat64()
+	}
+	rec, err := NewEEGRecording(data, 500, []string{"Fp1", "O1", "Cz"}, nil)
+	assert.Nil(t, err)
+
+	pairs := [][2]string{{"Fp1", "O1"}, {"Fp1", "Cz"}}
+	α := EEGBands.IndexOf("α")
+	for _, measure := range []ConnectivityMeasure{Coherence, PLV, WPLI} {
+		features, err := GetConnectivityForRecording(rec, 1000, 500, measure, pairs)
+		assert.Nil(t, err)
+		assert.Equal(t, len(ConnectivityNames(measure, pairs)), len(features[0]))
+
+		for _, f := range features {
+			assert.True(t, f[α] > 0.9, "%s: %v", measure, f)
+			assert.True(t, f[len(EEGBands)+α] < f[α], "%s: %v", measure, f)
+		}
+	}
+
+	all, err := GetConnectivityForRecording(rec, 1000, 500, PLV, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3*len(EEGBands), len(all[0]))
+
+	_, err = GetConnectivityForRecording(rec, 1000, 500, PLV, [][2]string{{"Fp1", "O2"}})
+	assert.NotNil(t, err)
+}
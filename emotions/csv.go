@@ -0,0 +1,100 @@
+package emotions
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// WriteCSVTo writes the features as csv with a header line
+// If header is nil the columns are named f0, f1 ...
+func WriteCSVTo(w io.Writer, header []string, features [][]float64) error {
+	if header == nil && len(features) > 0 {
+		header = make([]string, len(features[0]), len(features[0]))
+		for i := range header {
+			header[i] = fmt.Sprintf("f%d", i)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(header), len(header))
+	for i := range features {
+		if len(features[i]) != len(header) {
+			return fmt.Errorf("row %d has %d columns, the header has %d", i, len(features[i]), len(header))
+		}
+		for j, v := range features[i] {
+			record[j] = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadCSVFrom reads csv features with a header line and returns the header and the features
+func ReadCSVFrom(r io.Reader) ([]string, [][]float64, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var features [][]float64
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		row := make([]float64, len(record), len(record))
+		for j, s := range record {
+			if row[j], err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, nil, fmt.Errorf("line %d column %s: %s", line, header[j], err)
+			}
+		}
+		features = append(features, row)
+	}
+
+	return header, features, nil
+}
+
+// WriteCSV writes the features into a csv file with a header line
+func WriteCSV(filename string, header []string, features [][]float64) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteCSVTo(file, header, features); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	return file.Close()
+}
+
+// ReadCSV reads the header and the features from a csv file
+func ReadCSV(filename string) ([]string, [][]float64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	header, features, err := ReadCSVFrom(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return header, features, nil
+}
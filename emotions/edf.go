@@ -0,0 +1,323 @@
+package emotions
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// edfAnnotations is the label of the signal which holds the annotations of EDF+
+const edfAnnotations = "EDF Annotations"
+
+// EDFSignal is the header of a single signal in an EDF file
+type EDFSignal struct {
+	Label         string
+	Transducer    string
+	Unit          string
+	PhysicalMin   float64
+	PhysicalMax   float64
+	DigitalMin    float64
+	DigitalMax    float64
+	Prefiltering  string
+	SamplesPerRec int
+}
+
+// physical converts a digital sample to the physical unit of the signal
+func (s EDFSignal) physical(digital int16) float64 {
+	if s.DigitalMax == s.DigitalMin {
+		return float64(digital)
+	}
+	return (float64(digital)-s.DigitalMin)*(s.PhysicalMax-s.PhysicalMin)/(s.DigitalMax-s.DigitalMin) + s.PhysicalMin
+}
+
+// EDFHeader is the header of an EDF or EDF+ file
+type EDFHeader struct {
+	Patient   string
+	Recording string
+	Start     time.Time
+	// Reserved is "EDF+C" or "EDF+D" for EDF+ files
+	Reserved       string
+	Records        int
+	RecordDuration float64
+	Signals        []EDFSignal
+}
+
+// edfReader reads the fixed width ascii fields of the header
+type edfReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *edfReader) field(width int) string {
+	if r.err != nil {
+		return ""
+	}
+	b := make([]byte, width, width)
+	if _, err := io.ReadFull(r.reader, b); err != nil {
+		r.err = err
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func (r *edfReader) float(width int, name string) float64 {
+	s := r.field(width)
+	if r.err != nil {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		r.err = fmt.Errorf("invalid %s %q", name, s)
+	}
+	return v
+}
+
+func (r *edfReader) int(width int, name string) int {
+	s := r.field(width)
+	if r.err != nil {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		r.err = fmt.Errorf("invalid %s %q", name, s)
+	}
+	return v
+}
+
+// parseEDFStart parses the dd.mm.yy and hh.mm.ss of the header, where years before 85 are after 2000
+func parseEDFStart(date string, clock string) (time.Time, error) {
+	start, err := time.Parse("02.01.06 15.04.05", date+" "+clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start %s %s", date, clock)
+	}
+	// the layout takes 69-99 as 19xx, but for edf only 85-99 are
+	if y := start.Year(); y < 1985 {
+		start = start.AddDate(100, 0, 0)
+	}
+	return start, nil
+}
+
+// ReadEDFHeader reads the header of an EDF or EDF+ file
+func ReadEDFHeader(reader io.Reader) (EDFHeader, error) {
+	r := &edfReader{reader: reader}
+
+	var header EDFHeader
+	if version := r.field(8); r.err == nil && version != "0" {
+		return EDFHeader{}, fmt.Errorf("unknown edf version %q", version)
+	}
+	header.Patient = r.field(80)
+	header.Recording = r.field(80)
+	date := r.field(8)
+	clock := r.field(8)
+	r.int(8, "header size")
+	header.Reserved = r.field(44)
+	header.Records = r.int(8, "number of records")
+	header.RecordDuration = r.float(8, "record duration")
+	ns := r.int(4, "number of signals")
+	if r.err != nil {
+		return EDFHeader{}, r.err
+	}
+	if ns < 0 {
+		return EDFHeader{}, fmt.Errorf("invalid number of signals %d", ns)
+	}
+
+	start, err := parseEDFStart(date, clock)
+	if err != nil {
+		return EDFHeader{}, err
+	}
+	header.Start = start
+
+	// every field of the signals comes for all the signals before the next field
+	header.Signals = make([]EDFSignal, ns, ns)
+	for i := range header.Signals {
+		header.Signals[i].Label = r.field(16)
+	}
+	for i := range header.Signals {
+		header.Signals[i].Transducer = r.field(80)
+	}
+	for i := range header.Signals {
+		header.Signals[i].Unit = r.field(8)
+	}
+	for i := range header.Signals {
+		header.Signals[i].PhysicalMin = r.float(8, "physical minimum")
+	}
+	for i := range header.Signals {
+		header.Signals[i].PhysicalMax = r.float(8, "physical maximum")
+	}
+	for i := range header.Signals {
+		header.Signals[i].DigitalMin = r.float(8, "digital minimum")
+	}
+	for i := range header.Signals {
+		header.Signals[i].DigitalMax = r.float(8, "digital maximum")
+	}
+	for i := range header.Signals {
+		header.Signals[i].Prefiltering = r.field(80)
+	}
+	for i := range header.Signals {
+		header.Signals[i].SamplesPerRec = r.int(8, "number of samples")
+	}
+	for range header.Signals {
+		r.field(32)
+	}
+	if r.err != nil {
+		return EDFHeader{}, r.err
+	}
+
+	for _, s := range header.Signals {
+		if s.SamplesPerRec < 0 {
+			return EDFHeader{}, fmt.Errorf("invalid number of samples %d of %s", s.SamplesPerRec, s.Label)
+		}
+	}
+	return header, nil
+}
+
+// parseTALs parses the time-stamped annotation lists of an EDF+ annotation signal in a record
+// "+onset\x15duration\x14annotation\x14...\x00" where the first one of every record only keeps the time
+func parseTALs(data []byte, start time.Time) ([]Event, error) {
+	var events []Event
+	for _, tal := range strings.Split(string(data), "\x00") {
+		if tal == "" {
+			continue
+		}
+
+		parts := strings.Split(tal, "\x14")
+		timing := strings.SplitN(parts[0], "\x15", 2)
+		onset, err := strconv.ParseFloat(timing[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation onset %q", timing[0])
+		}
+		var duration float64
+		if len(timing) == 2 && timing[1] != "" {
+			duration, err = strconv.ParseFloat(timing[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid annotation duration %q", timing[1])
+			}
+		}
+
+		for _, text := range parts[1:] {
+			if text == "" {
+				continue
+			}
+			events = append(events, Event{
+				Onset:    start.Add(seconds(onset)),
+				Duration: seconds(duration),
+				Label:    text,
+			})
+		}
+	}
+	return events, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// ReadEDFFrom reads an EDF or continuous EDF+ file into a recording in the physical units of the signals
+// The signals with a different sample rate than the first one (e.g. a slower status channel) are left out
+// The EDF+ annotations are returned as events
+func ReadEDFFrom(reader io.Reader) (EEGRecording, []Event, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := ReadEDFHeader(buffered)
+	if err != nil {
+		return EEGRecording{}, nil, err
+	}
+	if strings.HasPrefix(header.Reserved, "EDF+D") {
+		return EEGRecording{}, nil, fmt.Errorf("discontinuous EDF+ is not supported")
+	}
+	if header.RecordDuration <= 0 {
+		return EEGRecording{}, nil, fmt.Errorf("invalid record duration %g", header.RecordDuration)
+	}
+
+	var kept []int
+	for i, s := range header.Signals {
+		if s.Label == edfAnnotations {
+			continue
+		}
+		if len(kept) == 0 || s.SamplesPerRec == header.Signals[kept[0]].SamplesPerRec {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) == 0 {
+		return EEGRecording{}, nil, fmt.Errorf("no data signals")
+	}
+
+	rate := float64(header.Signals[kept[0]].SamplesPerRec) / header.RecordDuration
+	if rate != math.Floor(rate) {
+		return EEGRecording{}, nil, fmt.Errorf("sample rate %g is not whole", rate)
+	}
+
+	channels := make([]string, len(kept), len(kept))
+	units := make([]string, len(kept), len(kept))
+	data := make([][]float64, len(kept), len(kept))
+	index := make(map[int]int, len(kept))
+	for c, i := range kept {
+		channels[c] = header.Signals[i].Label
+		units[c] = header.Signals[i].Unit
+		// the number of records in the header only sizes the buffers, the data ends them
+		data[c] = make([]float64, 0, Min(Max(header.Records, 0)*header.Signals[i].SamplesPerRec, maxMatrixValues))
+		index[i] = c
+	}
+
+	var events []Event
+	// the number of records is -1 while recording, then they are read until the end of the file
+records:
+	for record := 0; header.Records < 0 || record < header.Records; record++ {
+		for i, s := range header.Signals {
+			samples := make([]int16, s.SamplesPerRec, s.SamplesPerRec)
+			err := binary.Read(buffered, binary.LittleEndian, samples)
+			if err == io.EOF && i == 0 && header.Records < 0 {
+				break records
+			}
+			if err != nil {
+				return EEGRecording{}, nil, fmt.Errorf("record %d signal %s: %s", record, s.Label, err)
+			}
+
+			if s.Label == edfAnnotations {
+				bytes := make([]byte, 2*len(samples), 2*len(samples))
+				for j, sample := range samples {
+					binary.LittleEndian.PutUint16(bytes[2*j:], uint16(sample))
+				}
+				annotations, err := parseTALs(bytes, header.Start)
+				if err != nil {
+					return EEGRecording{}, nil, fmt.Errorf("record %d: %s", record, err)
+				}
+				events = append(events, annotations...)
+				continue
+			}
+
+			if c, ok := index[i]; ok {
+				for _, sample := range samples {
+					data[c] = append(data[c], s.physical(sample))
+				}
+			}
+		}
+	}
+
+	rec, err := NewEEGRecording(data, int(rate), channels, units)
+	if err != nil {
+		return EEGRecording{}, nil, err
+	}
+	rec.Start = header.Start
+	return rec, events, nil
+}
+
+// ReadEDF reads an EDF or EDF+ file (see ReadEDFFrom)
+func ReadEDF(filename string) (EEGRecording, []Event, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return EEGRecording{}, nil, err
+	}
+	defer file.Close()
+
+	rec, events, err := ReadEDFFrom(file)
+	if err != nil {
+		return EEGRecording{}, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return rec, events, nil
+}
@@ -0,0 +1,164 @@
+package emotions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func edfField(buffer *bytes.Buffer, width int, value string) {
+	fmt.Fprintf(buffer, "%-*s", width, value)
+}
+
+// edfHeader writes the header of two one second records of the given signals and the number of signals ns
+func edfHeader(b *bytes.Buffer, ns int, labels []string, units []string, samples []int) {
+	edfField(b, 8, "0")
+	edfField(b, 80, "X X X X")
+	edfField(b, 80, "Startdate 28-FEB-2019 X X X")
+	edfField(b, 8, "28.02.19")
+	edfField(b, 8, "10.25.30")
+	edfField(b, 8, fmt.Sprint(256*(len(labels)+1)))
+	edfField(b, 44, "EDF+C")
+	edfField(b, 8, "2")
+	edfField(b, 8, "1")
+	edfField(b, 4, fmt.Sprint(ns))
+	for _, l := range labels {
+		edfField(b, 16, l)
+	}
+	for range labels {
+		edfField(b, 80, "")
+	}
+	for _, unit := range units {
+		edfField(b, 8, unit)
+	}
+	for _, field := range []string{"-100", "100", "-32768", "32767"} {
+		for range labels {
+			edfField(b, 8, field)
+		}
+	}
+	for range labels {
+		edfField(b, 80, "")
+	}
+	for _, s := range samples {
+		edfField(b, 8, fmt.Sprint(s))
+	}
+	for range labels {
+		edfField(b, 32, "")
+	}
+}
+
+func TestReadEDF(t *testing.T) {
+	var b bytes.Buffer
+	labels := []string{"EEG Fp1", "EEG Cz", edfAnnotations}
+	samples := []int{4, 4, 30}
+	edfHeader(&b, len(labels), labels, []string{"uV", "uV", ""}, samples)
+
+	annotations := []string{"+0\x14\x14\x00+0.5\x150.25\x14anger\x14\x00", "+1\x14\x14\x00"}
+	for record := 0; record < 2; record++ {
+		binary.Write(&b, binary.LittleEndian, []int16{-32768, 32767, 0, 0})
+		binary.Write(&b, binary.LittleEndian, []int16{1, 2, 3, 4})
+		tal := make([]byte, 2*samples[2], 2*samples[2])
+		copy(tal, annotations[record])
+		b.Write(tal)
+	}
+
+	rec, events, err := ReadEDFFrom(&b)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, rec.SampleRate)
+	assert.Equal(t, []string{"EEG Fp1", "EEG Cz"}, rec.Channels)
+	assert.Equal(t, []string{"uV", "uV"}, rec.Units)
+	assert.Equal(t, 8, len(rec.Data[0]))
+	assert.InDelta(t, -100, rec.Data[0][0], 1e-9)
+	assert.InDelta(t, 100, rec.Data[0][1], 1e-9)
+
+	start := time.Date(2019, 2, 28, 10, 25, 30, 0, time.UTC)
+	assert.Equal(t, start, rec.Start)
+	assert.Equal(t, []Event{{Onset: start.Add(500 * time.Millisecond), Duration: 250 * time.Millisecond, Label: "anger"}}, events)
+
+	// the labels with the EEG prefix are still found in the montage
+	_, err = NewMontage(rec.Channels)
+	assert.Nil(t, err)
+}
+
+func TestParseEDFStart(t *testing.T) {
+	// the years 85-99 are 19xx and 00-84 are 20xx
+	for date, year := range map[string]int{"28.02.19": 2019, "28.02.70": 2070, "28.02.84": 2084, "28.02.85": 1985, "28.02.99": 1999} {
+		start, err := parseEDFStart(date, "10.25.30")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(year, 2, 28, 10, 25, 30, 0, time.UTC), start, date)
+	}
+
+	_, err := parseEDFStart("28.13.19", "10.25.30")
+	assert.Error(t, err)
+}
+
+func TestReadEDFHeaderCounts(t *testing.T) {
+	var b bytes.Buffer
+	edfHeader(&b, -1, nil, nil, nil)
+	_, err := ReadEDFHeader(&b)
+	assert.Error(t, err)
+
+	b.Reset()
+	edfHeader(&b, 1, []string{"EEG Cz"}, []string{"uV"}, []int{-5})
+	_, err = ReadEDFHeader(&b)
+	assert.Error(t, err)
+}
+
+func TestReadBrainVision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "brainvision")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	header := `Brain Vision Data Exchange Header File Version 1.0
+; comment
+
+[Common Infos]
+DataFile=rec.eeg
+MarkerFile=rec.vmrk
+DataFormat=BINARY
+DataOrientation=MULTIPLEXED
+NumberOfChannels=2
+SamplingInterval=2000
+
+[Binary Infos]
+BinaryFormat=INT_16
+
+[Channel Infos]
+Ch1=Fp1,,0.5,µV
+Ch2=Cz,,0.1,µV
+`
+	markers := `Brain Vision Data Exchange Marker File, Version 1.0
+
+[Marker Infos]
+Mk1=New Segment,,1,1,0,20190228102530000000
+Mk2=Stimulus,S  1,3,2,0
+Mk3=Comment,anger\1 loud,4,1,0
+`
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, []int16{2, 10, 4, 20, 6, 30, 8, 40})
+
+	ioutil.WriteFile(filepath.Join(dir, "rec.vhdr"), []byte(header), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "rec.vmrk"), []byte(markers), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "rec.eeg"), data.Bytes(), 0644)
+
+	rec, events, err := ReadBrainVision(filepath.Join(dir, "rec.vhdr"))
+	assert.Nil(t, err)
+	assert.Equal(t, 500, rec.SampleRate)
+	assert.Equal(t, []string{"Fp1", "Cz"}, rec.Channels)
+	assert.Equal(t, []float64{1, 2, 3, 4}, rec.Data[0])
+	assert.InDeltaSlice(t, []float64{1, 2, 3, 4}, rec.Data[1], 1e-9)
+
+	start := time.Date(2019, 2, 28, 10, 25, 30, 0, time.UTC)
+	assert.Equal(t, start, rec.Start)
+	assert.Equal(t, []Event{
+		{Onset: start.Add(4 * time.Millisecond), Duration: 4 * time.Millisecond, Label: "S  1"},
+		{Onset: start.Add(6 * time.Millisecond), Label: "anger, loud"},
+	}, events)
+}
@@ -1,18 +1,19 @@
 package emotions
 
 import (
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math"
 	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 type EegClusterable struct {
@@ -20,31 +21,7 @@ type EegClusterable struct {
 	Data  [][]float64 `json:"data"` //19x4
 }
 
-var waveRanges = [][2]float64{
-	[2]float64{4.0, 8.0},   // δ
-	[2]float64{8.0, 12.0},  // α
-	[2]float64{12.0, 30.0}, // β
-	[2]float64{30.0, 50.0}, // γ
-}
-
-func getRange(n float64) int {
-	if n > waveRanges[len(waveRanges)-1][1] {
-		return -1
-	}
-
-	if n < waveRanges[0][0] {
-		return -1
-	}
-
-	for i, w := range waveRanges {
-		if n > w[0] && n < w[1] {
-			return i
-		}
-	}
-	return -1
-}
-
-func getVector(line []string) []float64 {
+func getVector(line []string) ([]float64, error) {
 	floatValues := make([]float64, 0, len(line))
 
 	for _, s := range line {
@@ -54,46 +31,163 @@ func getVector(line []string) []float64 {
 
 		v, err := strconv.ParseFloat(s, 64)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid value %q", s)
 		}
 
 		floatValues = append(floatValues, v)
 	}
-	return floatValues
+	return floatValues, nil
 }
 
-// ReadXML takes an xml file with eeg readings and returns a vector for each electrode in time
-// where the first coordinate is the data from the first electrode and so on
-func ReadXML(filename string, elNum int) [][]float64 {
+// AllElectrodes can be passed as the number of electrodes to read all the electrodes in a file
+const AllElectrodes = 0
+
+// Jitter is gaussian noise with standard deviation Sigma added to every sample when the eeg is loaded
+// It keeps flat or quantised channels from giving zero variances, the noise is the same for the same Seed
+type Jitter struct {
+	Sigma float64
+	Seed  int64
+}
+
+func (j Jitter) String() string {
+	return fmt.Sprintf("σ=%g seed=%d", j.Sigma, j.Seed)
+}
+
+// apply adds the noise to the data in place
+func (j Jitter) apply(data [][]float64) {
+	if j.Sigma == 0 {
+		return
+	}
+
+	random := rand.New(rand.NewSource(j.Seed))
+	for i := range data {
+		for t := range data[i] {
+			data[i][t] += random.NormFloat64() * j.Sigma
+		}
+	}
+}
+
+// EEGJitter is the noise added to the samples by ReadEEG, none by default
+var EEGJitter = Jitter{}
+
+// ReadTicks reads the space separated ticks cut out of the xml export by the scripts
+// and returns a vector for each electrode in time, where the first coordinate is the data from the first electrode and so on
+// If elNum is AllElectrodes, the number of electrodes is taken from the first line
+func ReadTicks(filename string, elNum int) ([][]float64, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		panic(fmt.Sprintf("%s: %s", filename, err))
+		return nil, err
 	}
+	defer file.Close()
 
 	electrodes := make([][]float64, elNum, elNum)
 	scanner := csv.NewReader(file)
 	scanner.Comma = ' '
+	scanner.FieldsPerRecord = -1
 
-	for {
-		line, err := scanner.Read()
+	for line := 1; ; line++ {
+		fields, err := scanner.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				panic(fmt.Sprintf("%s %s", filename, err))
-			}
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
 		}
 
-		for i, value := range getVector(line) {
-			electrodes[i] = append(electrodes[i], value+rand.NormFloat64()*0.0001)
+		values, err := getVector(fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+		if elNum == AllElectrodes {
+			elNum = len(values)
+			electrodes = make([][]float64, elNum, elNum)
+		}
+		if len(values) != elNum {
+			return nil, fmt.Errorf("%s:%d: %d values for %d electrodes", filename, line, len(values), elNum)
 		}
+
+		for i, value := range values {
+			electrodes[i] = append(electrodes[i], value)
+		}
+	}
+
+	return electrodes, nil
+}
+
+// ReadXML takes the space separated ticks cut out of the xml export (see ReadTicks) with EEGJitter added
+// It panics on invalid files, ReadEEG returns the error instead
+func ReadXML(filename string, elNum int) [][]float64 {
+	electrodes, err := ReadTicks(filename, elNum)
+	if err != nil {
+		panic(err)
 	}
 
+	EEGJitter.apply(electrodes)
 	return electrodes
 }
 
-func cutElectrodeIntoFrames(electrode []float64, frameLen int, frameStep int, verbose bool) [][]float64 {
-	return CutSliceIntoFrames(electrode, 500, frameLen, frameStep, verbose)
+// ReadEEG reads an EDF/EDF+ file, a BrainVision header, the xml export of the headset
+// or the space separated ticks cut out of it by the scripts, and adds EEGJitter to the samples
+func ReadEEG(filename string, elNum int) (EEGRecording, error) {
+	var rec EEGRecording
+	var err error
+
+	switch {
+	case strings.EqualFold(filepath.Ext(filename), ".edf"):
+		rec, _, err = ReadEDF(filename)
+	case strings.EqualFold(filepath.Ext(filename), ".vhdr"):
+		rec, _, err = ReadBrainVision(filename)
+	case isXML(filename):
+		rec, err = ReadEEGXML(filename)
+	default:
+		var data [][]float64
+		data, err = ReadTicks(filename, elNum)
+		rec = EEGRecording{
+			SampleRate: DefaultEEGSampleRate,
+			Channels:   channelNames(len(data)),
+			Units:      channelUnits(len(data)),
+			Data:       data,
+		}
+	}
+	if err != nil {
+		return EEGRecording{}, err
+	}
+
+	EEGJitter.apply(rec.Data)
+	return rec, nil
+}
+
+// readEEG is ReadEEG which panics on invalid files
+func readEEG(filename string, elNum int) EEGRecording {
+	rec, err := ReadEEG(filename, elNum)
+	if err != nil {
+		panic(err)
+	}
+	return rec
+}
+
+// isXML checks whether the first non blank character of the file opens a tag
+func isXML(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return false
+		}
+		if !unicode.IsSpace(r) && r != '\uFEFF' {
+			return r == '<'
+		}
+	}
+}
+
+func cutElectrodeIntoFrames(electrode []float64, sampleRate int, frameLen int, frameStep int, verbose bool) [][]float64 {
+	return CutSliceIntoFrames(electrode, uint32(sampleRate), frameLen, frameStep, verbose)
 }
 
 func fourierElectrode(frames [][]float64) [][]Complex {
@@ -106,15 +200,15 @@ func fourierElectrode(frames [][]float64) [][]Complex {
 }
 
 // getSignificantFreq takes fourier coefficients for each frame for an electrode
-// and returns an array frameNum x 4 in which the alpha, beta, gamma, theta accumulated powers are stored
-func getSignificantFreq(coefficients [][]Complex) [][]float64 {
+// and returns an array frameNum x len(EEGBands) in which the log of the accumulated power in every band is stored
+func getSignificantFreq(coefficients [][]Complex, sampleRate int) [][]float64 {
 	sFreq := make([][]float64, len(coefficients), len(coefficients))
 
 	for i := 0; i < len(coefficients); i++ {
-		sFreq[i] = make([]float64, 4, 4)
+		sFreq[i] = make([]float64, len(EEGBands), len(EEGBands))
 		for j := 0; j < len(coefficients[i]); j++ {
 			power := Power(coefficients[i][j])
-			w := getRange(IndToFreq(j, 500, len(coefficients[0])))
+			w := EEGBands.Index(IndToFreq(j, sampleRate, len(coefficients[0])))
 			if w == -1 {
 				continue
 			}
@@ -124,20 +218,20 @@ func getSignificantFreq(coefficients [][]Complex) [][]float64 {
 
 	for _, s := range sFreq {
 		for j := 0; j < len(s); j++ {
-			s[j] = math.Log(s[j])
+			s[j] = safeLog(s[j])
 		}
 	}
 
 	return sFreq
 }
 
-func getWavesMean(coefficients [][]Complex) []float64 {
-	means := make([]float64, len(waveRanges), len(waveRanges))
+func getWavesMean(coefficients [][]Complex, sampleRate int) []float64 {
+	means := make([]float64, len(EEGBands), len(EEGBands))
 	for i := 0; i < len(coefficients); i++ {
 		for j := 0; j < len(coefficients[0]); j++ {
 
 			power := Power(coefficients[i][j])
-			w := getRange(IndToFreq(j, 500, len(coefficients[0])))
+			w := EEGBands.Index(IndToFreq(j, sampleRate, len(coefficients[0])))
 			if w == -1 {
 				continue
 			}
@@ -149,66 +243,237 @@ func getWavesMean(coefficients [][]Complex) []float64 {
 	return means
 }
 
-func getElectrodeWavesDistribution(electrodeData []float64, frameLen int, frameStep int) []float64 {
-	frames := cutElectrodeIntoFrames(electrodeData, frameLen, frameStep, false)
+func getElectrodeWavesDistribution(electrodeData []float64, sampleRate int, frameLen int, frameStep int) []float64 {
+	frames := cutElectrodeIntoFrames(electrodeData, sampleRate, frameLen, frameStep, false)
 	fouriers := fourierElectrode(frames)
-	return getWavesMean(fouriers)
+	return getWavesMean(fouriers, sampleRate)
 }
 
-// GetFeatureVector returns the mean of Θ, α, β and γ waves for each of the given elNum electrodes
-// returns a vector 19x4
+// GetFeatureVector returns the mean power in each of EEGBands for each of the given elNum electrodes
+// returns a vector elNum x len(EEGBands)
 func GetFeatureVector(filename string, elNum int, frameLen int, frameStep int) [][]float64 {
-	data := ReadXML(filename, elNum)
-	features := make([][]float64, len(data), len(data))
-	for i, d := range data {
-		features[i] = getElectrodeWavesDistribution(d, frameLen, frameStep)
-	}
-
-	return features
+	return GetFeatureVectorForRecording(readEEG(filename, elNum), frameLen, frameStep)
 }
 
-func getFeaturesFromFiles(filenames []string, frameLen int, frameStep int) []EegClusterable {
+func getFeaturesFromFiles(filenames []string, frameLen int, frameStep int) ([]EegClusterable, error) {
 	trainingSet := make([]EegClusterable, len(filenames), len(filenames))
 
 	for i, file := range filenames {
 		filename := filepath.Base(file)
 		name := filename[0 : len(filename)-len(filepath.Ext(filename))]
-		newFeatures := GetFeatureVector(file, 19, frameLen, frameStep)
+		rec, err := ReadEEG(file, AllElectrodes)
+		if err != nil {
+			return nil, err
+		}
 		trainingSet[i] = EegClusterable{
 			Class: name,
-			Data:  newFeatures,
+			Data:  GetFeatureVectorForRecording(rec, frameLen, frameStep),
 		}
 	}
 
-	return trainingSet
+	return trainingSet, nil
 }
 
-func SaveEegTrainingSet(filenames []string, outputFilename string, frameLen int, frameStep int) {
-	features := getFeaturesFromFiles(filenames, frameLen, frameStep)
+func SaveEegTrainingSet(filenames []string, outputFilename string, frameLen int, frameStep int) error {
+	features, err := getFeaturesFromFiles(filenames, frameLen, frameStep)
+	if err != nil {
+		return err
+	}
 
 	bytes, err := json.Marshal(features)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	ioutil.WriteFile(outputFilename, bytes, 0644)
+	return ioutil.WriteFile(outputFilename, bytes, 0644)
 }
 
-func getEegTrainingSet(filename string) []EegClusterable {
+func getEegTrainingSet(filename string) ([]EegClusterable, error) {
 	var clusterables []EegClusterable
-	bytes, _ := ioutil.ReadFile(filename)
-	err := json.Unmarshal(bytes, &clusterables)
+	bytes, err := ioutil.ReadFile(filename)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	if err := json.Unmarshal(bytes, &clusterables); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
 	}
 
-	return clusterables
+	return clusterables, nil
 }
 
-// GetFourierForFile takes a filename and numbers of electrodes and returns the fourier transform of each electrode
+// preprocessEEG removes the ocular components from the recording read from the file if EEGICA.RemoveOcular is set
+func preprocessEEG(filename string, rec EEGRecording) (EEGRecording, error) {
+	if !EEGICA.RemoveOcular {
+		return rec, nil
+	}
+
+	cleaned, removed, err := RemoveOcularComponents(rec, EEGICA)
+	if err != nil {
+		return EEGRecording{}, fmt.Errorf("%s: %s", filename, err)
+	}
+	if len(removed) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: removed ocular components %v\n", filename, removed)
+	}
+	return cleaned, nil
+}
+
+// rejectArtifacts applies EEGArtifacts to the features of the frames of the recording read from the file
+// and returns the remaining frames with their weights, which are 1 unless the policy is ArtifactDownWeight
+func rejectArtifacts(filename string, rec EEGRecording, features [][]float64, frameLen int, frameStep int) ([][]float64, []float64, error) {
+	if EEGArtifacts.Policy == ArtifactKeep {
+		weights := make([]float64, len(features), len(features))
+		for i := range weights {
+			weights[i] = 1
+		}
+		return features, weights, nil
+	}
+
+	mask := DetectArtifacts(rec, frameLen, frameStep, EEGArtifacts)
+	if flagged := len(mask) - countClean(mask); flagged > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d of %d epochs with artifacts (%s)\n", filename, flagged, len(mask), EEGArtifacts.Policy)
+	}
+
+	rejected, weights, err := ApplyArtifactMask(features, mask, EEGArtifacts.Policy, EEGArtifacts.Weight)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return rejected, weights, nil
+}
+
+// withWeights returns the frames with their weights appended as the last coordinate
+// so that the weights follow the frames through ValidateFeatures and the cache
+func withWeights(features [][]float64, weights []float64) [][]float64 {
+	weighted := make([][]float64, len(features), len(features))
+	for i, f := range features {
+		weighted[i] = append(append(make([]float64, 0, len(f)+1), f...), weights[i])
+	}
+	return weighted
+}
+
+// splitWeights returns the frames and the weights joined by withWeights
+func splitWeights(weighted [][]float64) ([][]float64, []float64) {
+	features := make([][]float64, len(weighted), len(weighted))
+	weights := make([]float64, len(weighted), len(weighted))
+	for i, w := range weighted {
+		features[i] = w[:len(w)-1]
+		weights[i] = w[len(w)-1]
+	}
+	return features, weights
+}
+
+// eegFeaturesConfig describes everything the features of an eeg file depend on for the cache
+func eegFeaturesConfig(kind string, elNum int, frameLen int, frameStep int) string {
+	return featureConfig("%s electrodes=%d frameLen=%d frameStep=%d bands=%s artifacts=%s ica=%s jitter=%s",
+		kind, elNum, frameLen, frameStep, EEGBands, EEGArtifacts, EEGICA, EEGJitter)
+}
+
+// loadEEGFeatures reads and preprocesses the file and returns the features of the recording
+// validated with FeaturePolicy and with the artifacts handled according to EEGArtifacts
+// The weights of the frames (see rejectArtifacts) are dropped, loadWeightedEEGFeatures keeps them
+func loadEEGFeatures(filename string, elNum int, frameLen int, frameStep int, features func(EEGRecording) ([][]float64, error)) ([][]float64, error) {
+	weighted, err := loadWeightedEEGFeatures(filename, elNum, frameLen, frameStep, features)
+	if err != nil {
+		return nil, err
+	}
+	computed, _ := splitWeights(weighted)
+	return computed, nil
+}
+
+// loadWeightedEEGFeatures is loadEEGFeatures which returns the frames with their weights as the last coordinate (see withWeights)
+func loadWeightedEEGFeatures(filename string, elNum int, frameLen int, frameStep int, features func(EEGRecording) ([][]float64, error)) ([][]float64, error) {
+	rec, err := ReadEEG(filename, elNum)
+	if err != nil {
+		return nil, err
+	}
+	if rec, err = preprocessEEG(filename, rec); err != nil {
+		return nil, err
+	}
+	if !hasFrames(rec, frameLen, frameStep) {
+		return nil, fmt.Errorf("%s: the recording of %s is too short for a frame of %dms", filename, rec.Duration(), frameLen)
+	}
+
+	computed, err := features(rec)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	computed, weights, err := rejectArtifacts(filename, rec, computed, frameLen, frameStep)
+	if err != nil {
+		return nil, err
+	}
+
+	validated, found, err := ValidateFeatures(filename, withWeights(computed, weights), FeaturePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if len(found) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d non-finite values in frames %v (%s)\n", filename, len(found), frameIndices(found), FeaturePolicy)
+	}
+	return validated, nil
+}
+
+// LoadFourierForFile returns the feature vectors of every frame of the eeg file (see GetFourierForRecording)
+// The ocular components are removed first if EEGICA.RemoveOcular is set and the artifacts are handled according to EEGArtifacts
+func LoadFourierForFile(filename string, elNum int, frameLen int, frameStep int) ([][]float64, error) {
+	return cachedFeaturesOrError(filename, eegFeaturesConfig("fourier", elNum, frameLen, frameStep), func() ([][]float64, error) {
+		return loadEEGFeatures(filename, elNum, frameLen, frameStep, func(rec EEGRecording) ([][]float64, error) {
+			return GetFourierForRecording(rec, frameLen, frameStep), nil
+		})
+	})
+}
+
+// LoadWeightedFourierForFile is LoadFourierForFile which also returns the weight of every frame
+// The flagged frames weigh EEGArtifacts.Weight with ArtifactDownWeight and all the rest 1
+// The weights are meant for KMeansOptions.Weights when training on the frames
+func LoadWeightedFourierForFile(filename string, elNum int, frameLen int, frameStep int) ([][]float64, []float64, error) {
+	weighted, err := cachedFeaturesOrError(filename, eegFeaturesConfig("weighted fourier", elNum, frameLen, frameStep), func() ([][]float64, error) {
+		return loadWeightedEEGFeatures(filename, elNum, frameLen, frameStep, func(rec EEGRecording) ([][]float64, error) {
+			return GetFourierForRecording(rec, frameLen, frameStep), nil
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	features, weights := splitWeights(weighted)
+	return features, weights, nil
+}
+
+// LoadAsymmetryForFile returns the DASM, RASM and DCAU features (see Asymmetry.Features) of every frame in the eeg file
+// preprocessed like in LoadFourierForFile
+func LoadAsymmetryForFile(filename string, elNum int, frameLen int, frameStep int) ([][]float64, error) {
+	return cachedFeaturesOrError(filename, eegFeaturesConfig("asymmetry rasm=power", elNum, frameLen, frameStep), func() ([][]float64, error) {
+		return loadEEGFeatures(filename, elNum, frameLen, frameStep, func(rec EEGRecording) ([][]float64, error) {
+			return GetAsymmetryForRecording(rec, frameLen, frameStep)
+		})
+	})
+}
+
+// LoadConnectivityForFile returns the measure between all the pairs of channels (see GetConnectivityForRecording)
+// of every frame in the eeg file preprocessed like in LoadFourierForFile
+func LoadConnectivityForFile(filename string, elNum int, frameLen int, frameStep int, measure ConnectivityMeasure) ([][]float64, error) {
+	return cachedFeaturesOrError(filename, eegFeaturesConfig(measure.String(), elNum, frameLen, frameStep), func() ([][]float64, error) {
+		return loadEEGFeatures(filename, elNum, frameLen, frameStep, func(rec EEGRecording) ([][]float64, error) {
+			return GetConnectivityForRecording(rec, frameLen, frameStep, measure, nil)
+		})
+	})
+}
+
+// GetFourierForFile is LoadFourierForFile which panics on invalid files
 func GetFourierForFile(filename string, elNum int, frameLen int, frameStep int) [][]float64 {
-	data := ReadXML(filename, elNum)
-	return getFourier(data, frameLen, frameStep)
+	features, err := LoadFourierForFile(filename, elNum, frameLen, frameStep)
+	if err != nil {
+		panic(err)
+	}
+	return features
+}
+
+// GetAsymmetryForFile is LoadAsymmetryForFile which panics on invalid files
+func GetAsymmetryForFile(filename string, elNum int, frameLen int, frameStep int) [][]float64 {
+	features, err := LoadAsymmetryForFile(filename, elNum, frameLen, frameStep)
+	if err != nil {
+		panic(err)
+	}
+	return features
 }
 
 func putSign(sign string, content []string) []string {
@@ -221,10 +486,13 @@ func putSign(sign string, content []string) []string {
 	return newContent
 }
 
-func readEEGfiles(filenames []string, frameLen int, frameStep int) []string {
+func readEEGfiles(filenames []string, frameLen int, frameStep int) ([]string, error) {
 	content := make([]string, 0, 1000)
 	for _, filename := range filenames {
-		cbf := GetFourierForFile(filename, 19, frameLen, frameStep)
+		cbf, err := LoadFourierForFile(filename, AllElectrodes, frameLen, frameStep)
+		if err != nil {
+			return nil, err
+		}
 
 		for _, c := range cbf {
 			if !IsZero(c) {
@@ -237,7 +505,7 @@ func readEEGfiles(filenames []string, frameLen int, frameStep int) []string {
 			}
 		}
 	}
-	return content
+	return content, nil
 }
 
 func writeToFile(filename string, content []string) error {
@@ -253,14 +521,23 @@ func writeToFile(filename string, content []string) error {
 
 // TrainEeg takes positive, negative and neutral eeg files and trains the svm models for each against the other
 func TrainEeg(eegPositiveFiles []string, eegNegativeFiles []string, eegNeutralFiles []string, outputDir string, frameLen int, frameStep int) error {
-	positive := readEEGfiles(eegPositiveFiles, frameLen, frameStep)
-	negative := readEEGfiles(eegNegativeFiles, frameLen, frameStep)
-	neutral := readEEGfiles(eegNeutralFiles, frameLen, frameStep)
+	positive, err := readEEGfiles(eegPositiveFiles, frameLen, frameStep)
+	if err != nil {
+		return err
+	}
+	negative, err := readEEGfiles(eegNegativeFiles, frameLen, frameStep)
+	if err != nil {
+		return err
+	}
+	neutral, err := readEEGfiles(eegNeutralFiles, frameLen, frameStep)
+	if err != nil {
+		return err
+	}
 
 	// +1 neutral -1 positive + negative
 	neutralNP := filepath.Join(outputDir, "neutral_np.txt")
 	neutralNPmodel := filepath.Join(outputDir, "neutral_np.model")
-	err := writeToFile(neutralNP, combineSlices(
+	err = writeToFile(neutralNP, combineSlices(
 		putSign("-1", combineSlices(negative, positive)),
 		putSign("+1", neutral),
 	))
@@ -307,26 +584,26 @@ func TrainEeg(eegPositiveFiles []string, eegNegativeFiles []string, eegNeutralFi
 	return nil
 }
 
-// getFouriers takes the inverted data (19xlen(eeg))
+// getFouriers takes the inverted data (numEl x len(eeg)) sampled at sampleRate
 // then cuts the data for each electrode into frames
 // For each frames we compute Fourier coefficients, then we accumulate these coefficients within the wave ranges
-// then we flip the result again, so we have the feature vectors which are numFrames x (numEl * 4)
-func getFourier(data [][]float64, frameLen int, frameStep int) [][]float64 {
+// then we flip the result again, so we have the feature vectors which are numFrames x (numEl * len(EEGBands))
+func getFourier(data [][]float64, sampleRate int, frameLen int, frameStep int) [][]float64 {
 	// fmt.Fprintf(os.Stderr, fmt.Sprintf("Data: %d x %d\n", len(data), len(data[0])))
-	// elFouriers is elNum x numFrames x 4(numWaves)
+	// elFouriers is elNum x numFrames x len(EEGBands)
 	elFouriers := make([]([][]float64), len(data), len(data))
 
 	for i, d := range data {
-		frames := cutElectrodeIntoFrames(d, frameLen, frameStep, false)
+		frames := cutElectrodeIntoFrames(d, sampleRate, frameLen, frameStep, false)
 
 		fouriers := fourierElectrode(frames)
-		elFouriers[i] = getSignificantFreq(fouriers)
+		elFouriers[i] = getSignificantFreq(fouriers, sampleRate)
 	}
 
 	// fmt.Printf("El fouriers: %d x %d x %d\n", len(elFouriers), len(elFouriers[0]), len(elFouriers[0][0]))
 
 	// fourierByFrames stores for every frame the waves for each electrode
-	// dim: numFrames x (numEl * 4)
+	// dim: numFrames x (numEl * len(EEGBands))
 	fourierByFrame := make([][]float64, len(elFouriers[0]), len(elFouriers[0]))
 	for i := range elFouriers[0] {
 		fourierByFrame[i] = make([]float64, 0, len(data)*len(elFouriers[0][0]))
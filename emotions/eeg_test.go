@@ -0,0 +1,44 @@
+package emotions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEEG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eeg")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	good := filepath.Join(dir, "good.txt")
+	bad := filepath.Join(dir, "bad.txt")
+	ioutil.WriteFile(good, []byte("1 2 3\n4 5 6\n"), 0644)
+	ioutil.WriteFile(bad, []byte("1 2 3\n4 x 6\n"), 0644)
+
+	rec, err := ReadEEG(good, AllElectrodes)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]float64{{1, 4}, {2, 5}, {3, 6}}, rec.Data)
+
+	_, err = ReadEEG(bad, AllElectrodes)
+	assert.True(t, strings.HasPrefix(err.Error(), bad+":2:"), err.Error())
+	_, err = ReadEEG(good, 2)
+	assert.NotNil(t, err)
+	_, err = LoadFourierForFile(bad, AllElectrodes, 200, 150)
+	assert.NotNil(t, err)
+	_, err = LoadEegFeaturesForFile(0, bad)
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { GetEegFeaturesForFile(0, bad) })
+
+	// the same seed gives the same noise
+	defer func(jitter Jitter) { EEGJitter = jitter }(EEGJitter)
+	EEGJitter = Jitter{Sigma: 0.1, Seed: 7}
+	first, _ := ReadEEG(good, AllElectrodes)
+	second, _ := ReadEEG(good, AllElectrodes)
+	assert.Equal(t, first.Data, second.Data)
+	assert.NotEqual(t, rec.Data, first.Data)
+}
@@ -0,0 +1,185 @@
+package emotions
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EEGXMLReader streams the ticks of the xml export of the eeg headset
+//
+//	<StartRecordingDate>dd.mm.yyyy ...</StartRecordingDate>
+//	<StartRecordingTime>hh:mm:ss.fff</StartRecordingTime>
+//	... <tick>v1 v2 ... v19</tick> ...
+//
+// where the values use decimal commas
+// The export carries neither the sample rate nor the channel names,
+// so those of the headset (DefaultEEGSampleRate and DefaultEEGChannels) are assumed
+type EEGXMLReader struct {
+	decoder *xml.Decoder
+	date    string
+	time    string
+	// pending is the first tick, which is read while looking for the start of the recording
+	pending []float64
+	ticks   int
+}
+
+// NewEEGXMLReader reads the xml up to the first tick
+func NewEEGXMLReader(r io.Reader) (*EEGXMLReader, error) {
+	reader := &EEGXMLReader{decoder: xml.NewDecoder(r)}
+	// the export may declare a legacy charset, but everything we read from it is ascii
+	reader.decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	tick, err := reader.next()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reader.pending = tick
+
+	return reader, nil
+}
+
+// Start returns the moment the recording started, the wall clock time of the headset in UTC (see EEGRecording.Start)
+func (r *EEGXMLReader) Start() (time.Time, error) {
+	fields := strings.Fields(r.date)
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("missing StartRecordingDate")
+	}
+
+	// the date is followed by a localised year suffix, which we ignore
+	date := strings.TrimRight(fields[0], ".гgrY ")
+	return time.ParseInLocation("02.01.2006 15:04:05.999999999", date+" "+strings.TrimSpace(r.time), time.UTC)
+}
+
+// Next returns the values of all the electrodes in the next tick or io.EOF
+func (r *EEGXMLReader) Next() ([]float64, error) {
+	if r.pending != nil {
+		tick := r.pending
+		r.pending = nil
+		return tick, nil
+	}
+
+	return r.next()
+}
+
+func (r *EEGXMLReader) next() ([]float64, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "StartRecordingDate":
+			if err := r.decoder.DecodeElement(&r.date, &start); err != nil {
+				return nil, err
+			}
+		case "StartRecordingTime":
+			if err := r.decoder.DecodeElement(&r.time, &start); err != nil {
+				return nil, err
+			}
+		case "tick":
+			var text string
+			if err := r.decoder.DecodeElement(&text, &start); err != nil {
+				return nil, err
+			}
+			r.ticks++
+
+			tick, err := parseTick(text)
+			if err != nil {
+				line, _ := r.decoder.InputPos()
+				return nil, fmt.Errorf("line %d tick %d: %s", line, r.ticks, err)
+			}
+			return tick, nil
+		}
+	}
+}
+
+// parseTick parses the space separated values of a tick which use decimal commas
+func parseTick(text string) ([]float64, error) {
+	fields := strings.Fields(text)
+	tick := make([]float64, len(fields), len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(strings.Replace(f, ",", ".", 1), 64)
+		if err != nil {
+			return nil, err
+		}
+		tick[i] = v
+	}
+	return tick, nil
+}
+
+// ReadEEGXML reads the whole xml export of the eeg headset into a recording
+func ReadEEGXML(filename string) (EEGRecording, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return EEGRecording{}, err
+	}
+	defer file.Close()
+
+	reader, err := NewEEGXMLReader(file)
+	if err != nil {
+		return EEGRecording{}, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	start, err := reader.Start()
+	if err != nil {
+		return EEGRecording{}, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	rec := EEGRecording{
+		Start:      start,
+		SampleRate: DefaultEEGSampleRate,
+	}
+
+	for {
+		tick, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return EEGRecording{}, fmt.Errorf("%s: %s", filename, err)
+		}
+
+		if rec.Data == nil {
+			rec.Data = make([][]float64, len(tick), len(tick))
+		}
+		if len(tick) != len(rec.Data) {
+			return EEGRecording{}, fmt.Errorf("%s: tick %d has %d values instead of %d", filename, reader.ticks, len(tick), len(rec.Data))
+		}
+
+		for i, v := range tick {
+			rec.Data[i] = append(rec.Data[i], v)
+		}
+	}
+
+	rec.Channels = channelNames(len(rec.Data))
+	rec.Units = channelUnits(len(rec.Data))
+	return rec, nil
+}
+
+// channelNames returns the labels of the headset if it has n electrodes, or Ch1...Chn otherwise
+func channelNames(n int) []string {
+	if n == len(DefaultEEGChannels) {
+		channels := make([]string, n, n)
+		copy(channels, DefaultEEGChannels)
+		return channels
+	}
+
+	channels := make([]string, n, n)
+	for i := range channels {
+		channels[i] = fmt.Sprintf("Ch%d", i+1)
+	}
+	return channels
+}
@@ -0,0 +1,41 @@
+package emotions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const eegXML = `<?xml version="1.0" encoding="windows-1251"?>
+<EEG>
+	<StartRecordingDate>05.04.2018 г.</StartRecordingDate>
+	<StartRecordingTime>14:03:21.250</StartRecordingTime>
+	<ticks>
+		<tick>1,5 -2,25 3</tick>
+		<tick>4 5,125 -6</tick>
+	</ticks>
+</EEG>
+`
+
+func TestReadEEGXML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eegxml")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "eeg.xml")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(eegXML), 0644))
+
+	rec, err := ReadEEGXML(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 4, 5, 14, 3, 21, 250000000, time.UTC), rec.Start)
+	assert.Equal(t, DefaultEEGSampleRate, rec.SampleRate)
+	assert.Equal(t, []string{"Ch1", "Ch2", "Ch3"}, rec.Channels)
+	assert.Equal(t, [][]float64{[]float64{1.5, 4}, []float64{-2.25, 5.125}, []float64{3, -6}}, rec.Data)
+
+	assert.True(t, isXML(filename))
+	assert.Equal(t, rec.Data, readEEG(filename, 3).Data)
+}
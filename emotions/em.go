@@ -41,110 +41,122 @@ type AlphaEGM struct {
 
 // GMM returns the k gaussian mixures for the given data
 func GMM(mfccsFloats [][]float64, k int) GaussianMixture {
-	X, expectations, variances, numInCluster := KMeans(mfccsFloats, k)
+	return GMMWithOptions(mfccsFloats, k, DefaultKMeansOptions())
+}
 
-	// f, _ := os.Create("/tmp/danni")
-	// defer f.Close()
-	// fmt.Fprintf(f, "[%d][%d]\n", len(mfccsFloats), len(mfccsFloats[0]))
-	// for i, m := range mfccsFloats {
-	// 	fmt.Fprintf(f, "[%d] %v\n", i, m)
-	// }
+// GMMWithOptions is GMM initialised with KMeansWithOptions
+// The points are weighted by options.Weights in both the k-means and em
+func GMMWithOptions(mfccsFloats [][]float64, k int, options KMeansOptions) GaussianMixture {
+	result := KMeansWithOptions(mfccsFloats, k, options)
+	X := result.Clustered
 
-	fmt.Fprintf(os.Stderr, "\n==============EM================\n")
+	// the weight of every cluster
+	sizes := make([]float64, k, k)
+	var total float64
+	for i, x := range X {
+		sizes[x.clusterID] += options.weight(i)
+		total += options.weight(i)
+	}
 
 	gmixture := make(GaussianMixture, k, k)
 
 	for i := 0; i < k; i++ {
 		gmixture[i] = Gaussian{
-			Phi:          float64(numInCluster[i]) / float64(len(X)),
-			Expectations: expectations[i],
-			Variances:    variances[i],
+			Phi:          sizes[i] / total,
+			Expectations: result.Expectations[i],
+			Variances:    result.Variances[i],
 		}
 	}
 
-	return em(X, k, gmixture)
+	return em(X, options.Weights, k, gmixture, options.Workers, options.Observer)
 }
 
-func em(X []MfccClusterisable, k int, gMixture GaussianMixture) GaussianMixture {
+// em improves the mixture until the likelihood of X stops changing
+// Every point counts as many times as its weight in the M-step and the likelihood, all weigh 1 if weights is nil
+// The points are shared between the workers (see parallel) and the result doesn't depend on their number
+func em(X []MfccClusterisable, weights []float64, k int, gMixture GaussianMixture, workers int, observer TrainingObserver) GaussianMixture {
+	weight := func(i int) float64 {
+		if weights == nil {
+			return 1
+		}
+		return weights[i]
+	}
+	total := float64(len(X))
+	if weights != nil {
+		total = 0
+		for _, v := range weights {
+			total += v
+		}
+	}
+
+	observer = observerOrSilent(observer)
 	prevLikelihood := 0.0
 	likelihood := 0.0
 	step := 0
 
-	f, _ := os.Create("/tmp/foo")
-	defer f.Close()
-
-	fmt.Fprintf(f, "Expectation0\n")
-	for j := 0; j < k; j++ {
-		fmt.Fprintf(f, "%d %v\n", j, gMixture[j].Expectations)
-	}
-
-	fmt.Fprintf(f, "Variance0\n")
-	for j := 0; j < k; j++ {
-		fmt.Fprintf(f, "%d %v\n", j, gMixture[j].Variances)
-	}
-
 	for step < 200 {
-		fmt.Fprintf(f, "================= %d =================\n", step)
+		observer.IterationStart("em", 0, step)
 		w := make([][]float64, len(X), len(X))
-		var sum float64
-		maximums := make([]float64, len(X), len(X))
-
-		for i := 0; i < len(maximums); i++ {
-			maximums[i] = math.Inf(-1)
-		}
+		parallel(len(X), workers, func(_ int, from int, to int) {
+			for i := from; i < to; i++ {
+				w[i] = make([]float64, k, k)
+				maximum := math.Inf(-1)
 
-		for i := 0; i < len(X); i++ {
-			w[i] = make([]float64, k, k)
+				for j := 0; j < k; j++ {
+					w[i][j] = math.Log(gMixture[j].Phi) + N(X[i].coefficients, gMixture[j].Expectations, gMixture[j].Variances)
 
-			for j := 0; j < k; j++ {
-				w[i][j] = math.Log(gMixture[j].Phi) + N(X[i].coefficients, gMixture[j].Expectations, gMixture[j].Variances)
+					if maximum < w[i][j] {
+						maximum = w[i][j]
+					}
+				}
 
-				if maximums[i] < w[i][j] {
-					maximums[i] = w[i][j]
+				var sum float64
+				for j := 0; j < k; j++ {
+					if w[i][j] < maximum-10 {
+						w[i][j] = 0
+					} else {
+						w[i][j] = math.Exp(w[i][j] - maximum)
+						sum += w[i][j]
+					}
 				}
-			}
 
-			sum = 0
+				// the responsibilities are scaled by the weight of the point, so all the sums of the M-step are weighted
+				divide(&w[i], sum/weight(i))
+			}
+		})
+
+		// every shard sums its weights and weighted points, which are added up in order
+		shards := numShards(len(X))
+		partialN := make([][]float64, shards, shards)
+		partialSums := make([][][]float64, shards, shards)
+		parallel(len(X), workers, func(shard int, from int, to int) {
+			partialN[shard] = make([]float64, k, k)
+			partialSums[shard] = make([][]float64, k, k)
 			for j := 0; j < k; j++ {
-				if w[i][j] < maximums[i]-10 {
-					w[i][j] = 0
-				} else {
-					w[i][j] = math.Exp(w[i][j] - maximums[i])
-					sum += w[i][j]
-				}
+				partialSums[shard][j] = make([]float64, len(X[0].coefficients), len(X[0].coefficients))
 			}
 
-			divide(&w[i], sum)
-		}
-
-		fmt.Fprintf(f, "W\n")
-		for i := range X {
-			fmt.Fprintf(f, "%d %v\n", i, w[i])
-		}
+			for i := from; i < to; i++ {
+				for j := 0; j < k; j++ {
+					partialN[shard][j] += w[i][j]
+					add(&partialSums[shard][j], multiplied(X[i].coefficients, w[i][j]))
+				}
+			}
+		})
 
 		N := make([]float64, k, k)
-		for i := 0; i < len(X); i++ {
+		for shard := range partialN {
 			for j := 0; j < k; j++ {
-				N[j] += w[i][j]
+				N[j] += partialN[shard][j]
 			}
 		}
 
-		fmt.Fprintf(f, "Expectation1\n")
-		for j := 0; j < k; j++ {
-			fmt.Fprintf(f, "%d %v\n", j, gMixture[j].Expectations)
-		}
-
-		fmt.Fprintf(f, "Variance1\n")
-		for j := 0; j < k; j++ {
-			fmt.Fprintf(f, "%d %v\n", j, gMixture[j].Variances)
-		}
-
 		zeroMixture(gMixture, k)
 
 		// Expectations
-		for i := 0; i < len(X); i++ {
+		for shard := range partialSums {
 			for j := 0; j < k; j++ {
-				add(&gMixture[j].Expectations, multiplied(X[i].coefficients, w[i][j]))
+				add(&gMixture[j].Expectations, partialSums[shard][j])
 			}
 		}
 
@@ -153,32 +165,39 @@ func em(X []MfccClusterisable, k int, gMixture GaussianMixture) GaussianMixture
 		}
 
 		// Variances
-		for i := 0; i < len(X); i++ {
+		parallel(len(X), workers, func(shard int, from int, to int) {
 			for j := 0; j < k; j++ {
-				diagonal := minused(X[i].coefficients, gMixture[j].Expectations)
-				square(&diagonal)
+				zero(&partialSums[shard][j])
+			}
+
+			for i := from; i < to; i++ {
+				for j := 0; j < k; j++ {
+					diagonal := minused(X[i].coefficients, gMixture[j].Expectations)
+					square(&diagonal)
 
-				add(&gMixture[j].Variances, multiplied(diagonal, w[i][j]))
+					add(&partialSums[shard][j], multiplied(diagonal, w[i][j]))
+				}
+			}
+		})
+		for shard := range partialSums {
+			for j := 0; j < k; j++ {
+				add(&gMixture[j].Variances, partialSums[shard][j])
 			}
 		}
 
 		// Phi and 1/Nk
 		for j := 0; j < k; j++ {
 			divide(&(gMixture[j].Variances), N[j])
-			gMixture[j].Phi = N[j] / float64(len(X))
+			gMixture[j].Phi = N[j] / total
 		}
 
-		fmt.Fprintf(f, "Expectation2\n")
-		for j := 0; j < k; j++ {
-			fmt.Fprintf(f, "%d %v\n", j, gMixture[j].Expectations)
-		}
-
-		fmt.Fprintf(f, "Variance2\n")
-		for j := 0; j < k; j++ {
-			fmt.Fprintf(f, "%d %v\n", j, gMixture[j].Variances)
-		}
-
-		likelihood = logLikelihood(X, k, gMixture)
+		likelihood = logLikelihood(X, weights, k, gMixture, workers)
+		observer.IterationEnd(TrainingIteration{
+			Algorithm:     "em",
+			Iteration:     step,
+			LogLikelihood: likelihood,
+			Mixture:       gMixture,
+		})
 
 		if math.IsNaN(likelihood) {
 			panic(fmt.Sprintf("Likelihood is NAN, step: %d", step))
@@ -192,7 +211,6 @@ func em(X []MfccClusterisable, k int, gMixture GaussianMixture) GaussianMixture
 		step++
 	}
 
-	fmt.Fprintf(os.Stderr, "EM: Break on step: %d with likelihood: %f\n===================================================\n", step, likelihood)
 	return gMixture
 }
 
@@ -426,12 +444,14 @@ func getBest(dict map[string]int) string {
 }
 
 func TestGMMBoth(emotion string, emotionTypes []string, speechAlphaEGM []AlphaEGM, speechEGM []EmotionGausianMixure, speechFile string, eegAlphaEGM []AlphaEGM, eegEGM []EmotionGausianMixure, eegFile string, bucketSize int) (int, int, int, string) {
+	return testGMMBoth(emotion, emotionTypes, speechAlphaEGM, speechEGM, GetSpeechFeatureForFile(speechFile), eegAlphaEGM, eegEGM, GetEegFeaturesForFile(bucketSize, eegFile))
+}
+
+// testGMMBoth is TestGMMBoth with the features already read
+func testGMMBoth(emotion string, emotionTypes []string, speechAlphaEGM []AlphaEGM, speechEGM []EmotionGausianMixure, speechFeatures [][]float64, eegAlphaEGM []AlphaEGM, eegEGM []EmotionGausianMixure, eegFeatures [][]float64) (int, int, int, string) {
 	kS := len(speechAlphaEGM[0].EGM.GM)
 	kE := len(eegAlphaEGM[0].EGM.GM)
 
-	speechFeatures := GetSpeechFeatureForFile(speechFile)
-	eegFeatures := GetEegFeaturesForFile(bucketSize, eegFile)
-
 	speechClassified, sumSpeech := FindBestGaussianMany(speechFeatures, kS, speechEGM)
 
 	eegClassified, sumEEG := FindBestGaussianMany(eegFeatures, kE, eegEGM)
@@ -568,10 +588,22 @@ func likelihoodFloat(X []float64, k int, g GaussianMixture) (float64, error) {
 
 // sum_i log(sum_j phi_j * N(x[i], m[k], s[k]))
 
-func logLikelihood(X []MfccClusterisable, k int, g GaussianMixture) float64 {
+// logLikelihood returns the sum of the log likelihoods of the points times their weights, which are all 1 if weights is nil
+func logLikelihood(X []MfccClusterisable, weights []float64, k int, g GaussianMixture, workers int) float64 {
+	partial := make([]float64, numShards(len(X)), numShards(len(X)))
+	parallel(len(X), workers, func(shard int, from int, to int) {
+		for i := from; i < to; i++ {
+			if weights == nil {
+				partial[shard] += logLikelihoodFloat(X[i].coefficients, k, g)
+			} else {
+				partial[shard] += weights[i] * logLikelihoodFloat(X[i].coefficients, k, g)
+			}
+		}
+	})
+
 	sum := 0.0
-	for i := 0; i < len(X); i++ {
-		sum += logLikelihoodFloat(X[i].coefficients, k, g)
+	for _, p := range partial {
+		sum += p
 	}
 	return sum
 }
@@ -0,0 +1,74 @@
+package emotions
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGMMWorkers(t *testing.T) {
+	points := syntheticClusters(rand.New(rand.NewSource(9)), 1500, 3, 2)
+
+	var mixtures []GaussianMixture
+	for _, workers := range []int{1, 4} {
+		options := DefaultKMeansOptions()
+		options.Rand = rand.New(rand.NewSource(4))
+		options.Workers = workers
+		mixtures = append(mixtures, GMMWithOptions(points, 3, options))
+	}
+
+	assert.Equal(t, mixtures[0], mixtures[1])
+	phi := 0.0
+	for _, g := range mixtures[0] {
+		phi += g.Phi
+	}
+	assert.InDelta(t, 1, phi, 1e-9)
+}
+
+func TestGMMWeights(t *testing.T) {
+	points := syntheticClusters(rand.New(rand.NewSource(3)), 300, 2, 2)
+
+	// the points of weight 2 count like two copies of them
+	weights := make([]float64, len(points), len(points))
+	repeated := append([][]float64{}, points...)
+	for i := range points {
+		weights[i] = 1
+		if i%3 == 0 {
+			weights[i] = 2
+			repeated = append(repeated, points[i])
+		}
+	}
+
+	options := DefaultKMeansOptions()
+	options.Init = InitGiven
+	options.Centroids = [][]float64{points[0], points[1]}
+	plain := GMMWithOptions(repeated, 2, options)
+	options.Weights = weights
+	weighted := GMMWithOptions(points, 2, options)
+
+	for j := range plain {
+		assert.InDelta(t, plain[j].Phi, weighted[j].Phi, 1e-6)
+		assert.InDeltaSlice(t, plain[j].Expectations, weighted[j].Expectations, 1e-6)
+		assert.InDeltaSlice(t, plain[j].Variances, weighted[j].Variances, 1e-6)
+	}
+
+	options.Weights = weights[1:]
+	assert.Panics(t, func() { GMMWithOptions(points, 2, options) })
+}
+
+func BenchmarkGMM(b *testing.B) {
+	points := syntheticClusters(rand.New(rand.NewSource(1)), 20000, 8, 39)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				options := DefaultKMeansOptions()
+				options.Rand = rand.New(rand.NewSource(1))
+				options.MaxIter = 10
+				options.Workers = workers
+				GMMWithOptions(points, 8, options)
+			}
+		})
+	}
+}
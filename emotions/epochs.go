@@ -0,0 +1,242 @@
+package emotions
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a labelled stimulus, e.g. a video clip shown while recording the eeg
+type Event struct {
+	Onset    time.Time
+	Duration time.Duration
+	Label    string
+}
+
+// unixTime converts seconds since the epoch with a fraction to time
+func unixTime(seconds float64) time.Time {
+	whole := math.Floor(seconds)
+	return time.Unix(int64(whole), int64(math.Round((seconds-whole)*1e9)))
+}
+
+// ReadEvents reads an event list with a "label start end" line per event, where start and end are unix seconds
+// which is the format of scripts/audio_times.txt
+func ReadEvents(filename string) ([]Event, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected label, start and end, got %q", filename, line, scanner.Text())
+		}
+
+		start, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+		end, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+		if end < start {
+			return nil, fmt.Errorf("%s:%d: event ends before it starts", filename, line)
+		}
+
+		onset := unixTime(start)
+		events = append(events, Event{
+			Onset:    onset,
+			Duration: unixTime(end).Sub(onset),
+			Label:    fields[0],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return events, nil
+}
+
+// Epoch is the part of a continuous recording around an event
+type Epoch struct {
+	Event Event
+	// Baseline is the pre-stimulus interval [onset - pre, onset) or a resting block (see WithBaseline)
+	Baseline EEGRecording
+	// Stimulus is the interval [onset, onset + duration + post)
+	Stimulus EEGRecording
+}
+
+// EpochRecording cuts an epoch out of the continuous recording for every event
+// with pre before the onset for the baseline and post after the end of the event
+func EpochRecording(rec EEGRecording, events []Event, pre time.Duration, post time.Duration) ([]Epoch, error) {
+	end := rec.Start.Add(rec.Duration())
+
+	epochs := make([]Epoch, len(events), len(events))
+	for i, e := range events {
+		from := e.Onset.Add(-pre)
+		to := e.Onset.Add(e.Duration + post)
+		if from.Before(rec.Start) || to.After(end) {
+			return nil, fmt.Errorf("event %s [%s, %s] is outside the recording [%s, %s]", e.Label, from, to, rec.Start, end)
+		}
+
+		epochs[i] = Epoch{
+			Event:    e,
+			Baseline: rec.Between(from, e.Onset),
+			Stimulus: rec.Between(e.Onset, to),
+		}
+	}
+	return epochs, nil
+}
+
+// WithBaseline returns the epoch compared with the given block (e.g. a resting or neutral one) instead of its pre-stimulus interval
+// The block must have the channels of the stimulus in the same order and its sample rate
+func (e Epoch) WithBaseline(block EEGRecording) (Epoch, error) {
+	if block.SampleRate != e.Stimulus.SampleRate {
+		return Epoch{}, fmt.Errorf("event %s: baseline sample rate %d instead of %d", e.Event.Label, block.SampleRate, e.Stimulus.SampleRate)
+	}
+	if err := checkSameChannels(block, e.Stimulus); err != nil {
+		return Epoch{}, fmt.Errorf("event %s: %s", e.Event.Label, err)
+	}
+
+	e.Baseline = block
+	return e, nil
+}
+
+// checkSameChannels checks that the baseline has the channels of the stimulus in the same order
+func checkSameChannels(baseline EEGRecording, stimulus EEGRecording) error {
+	if len(baseline.Data) != len(stimulus.Data) || len(baseline.Channels) != len(stimulus.Channels) {
+		return fmt.Errorf("baseline channels %v instead of %v", baseline.Channels, stimulus.Channels)
+	}
+	for c := range stimulus.Channels {
+		if !strings.EqualFold(baseline.Channels[c], stimulus.Channels[c]) {
+			return fmt.Errorf("baseline channels %v instead of %v", baseline.Channels, stimulus.Channels)
+		}
+	}
+	return nil
+}
+
+// RemoveBaselineMean subtracts the mean of every channel in the baseline from the stimulus
+// which removes the offset of the electrodes but doesn't change the power
+// It fails for an empty baseline, e.g. of an epoch cut with no time before the onset
+func (e Epoch) RemoveBaselineMean() (Epoch, error) {
+	if err := checkSameChannels(e.Baseline, e.Stimulus); err != nil {
+		return Epoch{}, fmt.Errorf("event %s: %s", e.Event.Label, err)
+	}
+	for c := range e.Baseline.Data {
+		if len(e.Baseline.Data[c]) == 0 {
+			return Epoch{}, fmt.Errorf("event %s: empty baseline", e.Event.Label)
+		}
+	}
+
+	data := make([][]float64, len(e.Stimulus.Data), len(e.Stimulus.Data))
+	for c := range e.Stimulus.Data {
+		mean := statsOfValues(e.Baseline.Data[c]).Mean()[0]
+
+		data[c] = make([]float64, len(e.Stimulus.Data[c]), len(e.Stimulus.Data[c]))
+		for t, x := range e.Stimulus.Data[c] {
+			data[c][t] = x - mean
+		}
+	}
+
+	e.Stimulus = withChannels(e.Stimulus, e.Stimulus.Channels, e.Stimulus.Units, data)
+	return e, nil
+}
+
+// BaselineMode tells how the features of the stimulus are compared with the ones of the baseline
+type BaselineMode int
+
+const (
+	// BaselineNone leaves the features as they are
+	BaselineNone BaselineMode = iota
+	// BaselineSubtract subtracts the mean of the baseline, which for log powers is the log of their ratio
+	BaselineSubtract
+	// BaselineZScore subtracts the mean of the baseline and divides by its standard deviation
+	BaselineZScore
+)
+
+func (m BaselineMode) String() string {
+	switch m {
+	case BaselineNone:
+		return "none"
+	case BaselineSubtract:
+		return "subtract"
+	case BaselineZScore:
+		return "zscore"
+	default:
+		return fmt.Sprintf("BaselineMode(%d)", int(m))
+	}
+}
+
+// BaselineCorrect compares every frame of the features with the frames of the baseline
+func BaselineCorrect(features [][]float64, baseline [][]float64, mode BaselineMode) ([][]float64, error) {
+	if mode == BaselineNone {
+		return features, nil
+	}
+	if len(baseline) == 0 {
+		return nil, fmt.Errorf("no baseline frames")
+	}
+
+	for i, b := range baseline {
+		if len(b) != len(baseline[0]) {
+			return nil, fmt.Errorf("baseline frame %d has %d coordinates instead of %d", i, len(b), len(baseline[0]))
+		}
+	}
+	stats := StatsOf(baseline)
+	mean := stats.Mean()
+	σ := stats.StandardDeviation()
+
+	corrected := make([][]float64, len(features), len(features))
+	for i, f := range features {
+		if len(f) != len(mean) {
+			return nil, fmt.Errorf("frame %d has %d coordinates and the baseline %d", i, len(f), len(mean))
+		}
+
+		corrected[i] = minused(f, mean)
+		if mode == BaselineZScore {
+			for j := range corrected[i] {
+				if σ[j] > EPS {
+					corrected[i][j] /= σ[j]
+				}
+			}
+		}
+	}
+	return corrected, nil
+}
+
+// hasFrames checks whether at least one frame can be cut out of the recording
+func hasFrames(rec EEGRecording, frameLen int, frameStep int) bool {
+	return len(rec.Data) > 0 && len(epochBounds(len(rec.Data[0]), rec.SampleRate, frameLen, frameStep)) > 0
+}
+
+// GetFourierForEpoch returns the feature vectors of the stimulus (see GetFourierForRecording) compared with the ones of the baseline
+func GetFourierForEpoch(epoch Epoch, frameLen int, frameStep int, mode BaselineMode) ([][]float64, error) {
+	if !hasFrames(epoch.Stimulus, frameLen, frameStep) {
+		return nil, fmt.Errorf("event %s: stimulus of %s is too short for a frame", epoch.Event.Label, epoch.Stimulus.Duration())
+	}
+	features := GetFourierForRecording(epoch.Stimulus, frameLen, frameStep)
+	if mode == BaselineNone {
+		return features, nil
+	}
+
+	if err := checkSameChannels(epoch.Baseline, epoch.Stimulus); err != nil {
+		return nil, fmt.Errorf("event %s: %s", epoch.Event.Label, err)
+	}
+	if !hasFrames(epoch.Baseline, frameLen, frameStep) {
+		return nil, fmt.Errorf("event %s: baseline of %s is too short for a frame", epoch.Event.Label, epoch.Baseline.Duration())
+	}
+	return BaselineCorrect(features, GetFourierForRecording(epoch.Baseline, frameLen, frameStep), mode)
+}
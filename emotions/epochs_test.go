@@ -0,0 +1,74 @@
+package emotions
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEpochRecording(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "times.txt")
+	ioutil.WriteFile(filename, []byte("anger_01.mp4 1551349532.5 1551349534.5\n\nhappiness_01.mp4 1551349536 1551349537\n"), 0644)
+	events, err := ReadEvents(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "anger_01.mp4", events[0].Label)
+	assert.Equal(t, 2*time.Second, events[0].Duration)
+
+	// 10 seconds of 10Hz which is 3 times louder and offset by 2 during the anger clip
+	rec := sineRecording(10.0, 500, 10, []string{"Cz"})
+	rec.Start = time.Unix(1551349530, 0)
+	for i := 1250; i < 2250; i++ {
+		rec.Data[0][i] = 3*rec.Data[0][i] + 2
+	}
+
+	epochs, err := EpochRecording(rec, events, 2*time.Second, 500*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, len(epochs[0].Baseline.Data[0]))
+	assert.Equal(t, 1250, len(epochs[0].Stimulus.Data[0]))
+	assert.Equal(t, events[0].Onset, epochs[0].Stimulus.Start)
+
+	// the baseline has mean 0, so removing its mean keeps the offset of the clip
+	shifted, err := epochs[0].RemoveBaselineMean()
+	assert.Nil(t, err)
+	assert.InDelta(t, 2, shifted.Stimulus.Data[0][0], 1e-9)
+
+	// the offset is not in α and the power there is 9 times the one in the baseline
+	features, err := GetFourierForEpoch(epochs[0], 1000, 500, BaselineSubtract)
+	assert.Nil(t, err)
+	α := EEGBands.IndexOf("α")
+	assert.InDelta(t, math.Log(9), features[0][α], 1e-3)
+
+	_, err = GetFourierForEpoch(epochs[0], 2000, 500, BaselineSubtract)
+	assert.NotNil(t, err)
+
+	_, err = EpochRecording(rec, events, 10*time.Second, 0)
+	assert.NotNil(t, err)
+
+	// a resting block needs the channels and the sample rate of the stimulus
+	rest := sineRecording(10.0, 500, 2, []string{"Cz"})
+	rested, err := epochs[0].WithBaseline(rest)
+	assert.Nil(t, err)
+	assert.Equal(t, rest, rested.Baseline)
+	_, err = epochs[0].WithBaseline(sineRecording(10.0, 500, 2, []string{"Pz"}))
+	assert.NotNil(t, err)
+	_, err = epochs[0].WithBaseline(sineRecording(10.0, 500, 2, []string{"Cz", "Pz"}))
+	assert.NotNil(t, err)
+	_, err = epochs[0].WithBaseline(sineRecording(10.0, 250, 2, []string{"Cz"}))
+	assert.NotNil(t, err)
+
+	// without time before the onset there is no baseline mean
+	unbased, err := EpochRecording(rec, events, 0, 0)
+	assert.Nil(t, err)
+	_, err = unbased[0].RemoveBaselineMean()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,99 @@
+package emotions
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFeatures reads the feature matrices from a file chosen by its extension
+// .htk, .npy and .csv hold a single matrix, which is tagged with the name of the file without extension
+// .ark, .scp and .npz hold many tagged matrices
+// The result can be used as a KNN training set or concatenated and passed to GMM
+func ReadFeatures(filename string) ([]Tagged, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	tag := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	var data [][]float64
+	var err error
+	switch ext {
+	case ".ark":
+		return ReadKaldiArk(filename)
+	case ".scp":
+		return ReadKaldiScp(filename)
+	case ".npz":
+		return ReadNpz(filename)
+	case ".htk":
+		data, _, err = ReadHTK(filename)
+	case ".npy":
+		data, err = ReadNpy(filename)
+	case ".csv":
+		_, data, err = ReadCSV(filename)
+	default:
+		return nil, fmt.Errorf("unknown feature format: %s", filename)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return []Tagged{Tagged{Tag: tag, Data: data}}, nil
+}
+
+// WriteFeatures writes the tagged feature matrices into a file chosen by its extension
+// .ark also writes an .scp next to it, .htk (as USER features with STEP_IN_MS period), .npy and .csv accept a single matrix
+func WriteFeatures(filename string, features []Tagged) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	case ".ark":
+		return WriteKaldi(filename, strings.TrimSuffix(filename, filepath.Ext(filename))+".scp", features)
+	case ".npz":
+		return WriteNpz(filename, features)
+	}
+
+	if len(features) != 1 {
+		return fmt.Errorf("%s holds a single matrix, got %d", filename, len(features))
+	}
+	data := features[0].Data
+
+	switch ext {
+	case ".htk":
+		return WriteHTK(filename, data, NewHTKHeader(len(data), len(firstRow(data)), STEP_IN_MS, HTKUser))
+	case ".npy":
+		return WriteNpy(filename, data)
+	case ".csv":
+		return WriteCSV(filename, nil, data)
+	default:
+		return fmt.Errorf("unknown feature format: %s", filename)
+	}
+}
+
+// ConcatTagged puts the data of all the tagged matrices together, e.g. to train a single GMM
+func ConcatTagged(features []Tagged) [][]float64 {
+	var data [][]float64
+	for _, f := range features {
+		data = append(data, f.Data...)
+	}
+	return data
+}
+
+// maxMatrixValues bounds the matrices read from the files of other toolkits,
+// so a corrupt header fails with an error instead of allocating gigabytes or panicking
+const maxMatrixValues = 1 << 26
+
+// checkMatrixShape rejects the negative or too large dimensions of a matrix header
+func checkMatrixShape(rows int, cols int) error {
+	if rows < 0 || cols < 0 {
+		return fmt.Errorf("invalid matrix shape %d x %d", rows, cols)
+	}
+	if cols > maxMatrixValues || rows > maxMatrixValues/Max(cols, 1) {
+		return fmt.Errorf("matrix of %d x %d values is larger than %d", rows, cols, maxMatrixValues)
+	}
+	return nil
+}
+
+func firstRow(x [][]float64) []float64 {
+	if len(x) == 0 {
+		return nil
+	}
+	return x[0]
+}
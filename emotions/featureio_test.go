@@ -0,0 +1,79 @@
+package emotions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "featureio")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	first := Tagged{Tag: "first", Data: [][]float64{[]float64{1.5, -2.25, 3}, []float64{0.5, 4, -8}}}
+	second := Tagged{Tag: "second", Data: [][]float64{[]float64{7, 8, 9}}}
+
+	for _, name := range []string{"features.ark", "features.npz"} {
+		filename := filepath.Join(dir, name)
+		assert.Nil(t, WriteFeatures(filename, []Tagged{first, second}), name)
+
+		read, err := ReadFeatures(filename)
+		assert.Nil(t, err, name)
+		assert.Equal(t, []Tagged{first, second}, read, name)
+	}
+
+	read, err := ReadKaldiScp(filepath.Join(dir, "features.scp"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Tagged{first, second}, read)
+
+	for _, name := range []string{"first.htk", "first.npy", "first.csv"} {
+		filename := filepath.Join(dir, name)
+		assert.Nil(t, WriteFeatures(filename, []Tagged{first}), name)
+
+		read, err := ReadFeatures(filename)
+		assert.Nil(t, err, name)
+		assert.Equal(t, []Tagged{first}, read, name)
+	}
+
+	_, header, err := ReadHTK(filepath.Join(dir, "first.htk"))
+	assert.Nil(t, err)
+	assert.Equal(t, NewHTKHeader(2, 3, STEP_IN_MS, HTKUser), header)
+}
+
+func TestReadCorruptShapes(t *testing.T) {
+	// the counts of the headers are negative or far larger than the data
+	for _, samples := range []int32{-5, 1 << 30} {
+		var htk bytes.Buffer
+		binary.Write(&htk, binary.BigEndian, HTKHeader{Samples: samples, SamplePeriod: 100000, SampleSize: 12, ParmKind: HTKUser})
+		_, _, err := ReadHTKFrom(&htk)
+		assert.NotNil(t, err, "%d", samples)
+	}
+
+	for _, rows := range []int32{-3, 1 << 30} {
+		var kaldi bytes.Buffer
+		kaldi.WriteString("\x00BFM ")
+		for _, n := range []int32{rows, 2} {
+			kaldi.WriteByte(4)
+			binary.Write(&kaldi, binary.LittleEndian, n)
+		}
+		_, err := readKaldiMatrix(&kaldi)
+		assert.NotNil(t, err, "%d", rows)
+	}
+
+	for _, shape := range []string{"(-2, 3)", "(3, -2)", "(1000000000, 1000000000)"} {
+		header := "{'descr': '<f8', 'fortran_order': False, 'shape': " + shape + ", }\n"
+		var npy bytes.Buffer
+		npy.WriteString(npyMagic)
+		npy.Write([]byte{1, 0})
+		binary.Write(&npy, binary.LittleEndian, uint16(len(header)))
+		npy.WriteString(header)
+		_, err := ReadNpyFrom(&npy)
+		assert.NotNil(t, err, shape)
+	}
+}
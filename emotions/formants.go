@@ -0,0 +1,130 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	minFormantFreq      = 90.0
+	maxFormantBandwidth = 400.0
+)
+
+// polynomialRoots returns the roots of z^p + a[1]z^(p-1) + ... + a[p] using the Durand-Kerner iteration
+func polynomialRoots(a []float64) []Complex {
+	p := len(a) - 1
+	roots := make([]Complex, p, p)
+	if p == 0 {
+		return roots
+	}
+
+	// The starting points should be neither real nor roots of unity
+	seed := Complex{Re: 0.4, Im: 0.9}
+	roots[0] = Complex{Re: 1.0}
+	for i := 1; i < p; i++ {
+		roots[i] = dot(roots[i-1], seed)
+	}
+
+	for iteration := 0; iteration < 500; iteration++ {
+		maxChange := 0.0
+		for i := 0; i < p; i++ {
+			numerator := evaluatePolynomial(a, roots[i])
+			denominator := Complex{Re: 1.0}
+			for j := 0; j < p; j++ {
+				if i != j {
+					denominator = dot(denominator, roots[i].sub(roots[j]))
+				}
+			}
+			if Power(denominator) == 0 {
+				continue
+			}
+
+			change := quotient(numerator, denominator)
+			roots[i] = roots[i].sub(change)
+			maxChange = math.Max(maxChange, Magnitude(change))
+		}
+
+		if maxChange < 1e-10 {
+			break
+		}
+	}
+
+	return roots
+}
+
+// evaluatePolynomial computes z^p + a[1]z^(p-1) + ... + a[p] with Horner's method
+func evaluatePolynomial(a []float64, z Complex) Complex {
+	result := Complex{Re: a[0]}
+	for i := 1; i < len(a); i++ {
+		result = dot(result, z).add(Complex{Re: a[i]})
+	}
+	return result
+}
+
+// frameFormants returns the frequencies and the bandwidths of the first n formants of the polynomial A(z)
+// Formants which could not be found are 0
+func frameFormants(a []float64, sampleRate int, n int) ([]float64, []float64) {
+	type formant struct {
+		frequency float64
+		bandwidth float64
+	}
+
+	candidates := make([]formant, 0, len(a))
+	for _, root := range polynomialRoots(a) {
+		// the roots come in conjugate pairs, so we keep only the upper half plane
+		if root.Im <= 0 {
+			continue
+		}
+
+		frequency := math.Atan2(root.Im, root.Re) * float64(sampleRate) / (2 * math.Pi)
+		bandwidth := -math.Log(Magnitude(root)) * float64(sampleRate) / math.Pi
+		if frequency > minFormantFreq && bandwidth < maxFormantBandwidth {
+			candidates = append(candidates, formant{frequency, bandwidth})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].frequency < candidates[j].frequency })
+
+	frequencies := make([]float64, n, n)
+	bandwidths := make([]float64, n, n)
+	for i := 0; i < n && i < len(candidates); i++ {
+		frequencies[i] = candidates[i].frequency
+		bandwidths[i] = candidates[i].bandwidth
+	}
+
+	return frequencies, bandwidths
+}
+
+// Formants tracks the first n formants (n = 3 for F1 to F3) through the wav file
+// finding the roots of the order p lpc polynomial of every frame
+// It returns for every frame a vector with the n frequencies followed by their n bandwidths (in Hz)
+// When a formant is missing in a frame (silence or unvoiced speech) its previous value is kept
+// It panics unless n is positive and p is at least 2n, since every formant takes a pair of roots
+func Formants(wf WavFile, p int, n int) [][]float64 {
+	if n < 1 || p < 2*n {
+		panic(fmt.Sprintf("an lpc order of %d can't give %d formants", p, n))
+	}
+	frames := CutWavFileIntoFrames(wf)
+	formants := make([][]float64, len(frames), len(frames))
+
+	previous := make([]float64, 2*n, 2*n)
+	for i, frame := range frames {
+		a, _, _ := LPC(frame, p)
+		frequencies, bandwidths := frameFormants(a, wf.GetSampleRate(), n)
+
+		formants[i] = make([]float64, 2*n, 2*n)
+		for j := 0; j < n; j++ {
+			if frequencies[j] == 0 {
+				formants[i][j] = previous[j]
+				formants[i][n+j] = previous[n+j]
+				continue
+			}
+			formants[i][j] = frequencies[j]
+			formants[i][n+j] = bandwidths[j]
+		}
+		previous = formants[i]
+	}
+
+	return formants
+}
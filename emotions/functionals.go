@@ -0,0 +1,150 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultPercentiles are the percentiles (as fractions) reported by the functionals
+// 1% and 99% act as robust min/max, 20-50-80 are the ones used in eGeMAPS
+var DefaultPercentiles = []float64{0.01, 0.2, 0.5, 0.8, 0.99}
+
+// FunctionalNames returns the name of every statistic Functionals computes for a single dimension
+// in the order in which they appear in the output vector
+func FunctionalNames(percentiles []float64) []string {
+	names := []string{"mean", "std", "skewness", "kurtosis", "min", "max", "range"}
+	for _, p := range percentiles {
+		names = append(names, fmt.Sprintf("p%g", p*100))
+	}
+	return append(names, "slope", "rising")
+}
+
+// Functionals maps a frames x dims matrix (e.g. the mfccs of a single utterance) to one fixed-length vector
+// For every dimension d the vector contains (see FunctionalNames)
+// mean, std, skewness, kurtosis, min, max, range, the given percentiles,
+// the slope of the linear regression over the frame index and the fraction of frames which are rising
+// so the result has dims * (9 + len(percentiles)) coordinates, grouped by dimension
+func Functionals(frames [][]float64, percentiles []float64) []float64 {
+	if len(frames) == 0 {
+		panic("Functionals expects at least one frame")
+	}
+
+	dims := len(frames[0])
+	perDim := 9 + len(percentiles)
+	result := make([]float64, 0, dims*perDim)
+
+	column := make([]float64, len(frames), len(frames))
+	for d := 0; d < dims; d++ {
+		for i := range frames {
+			column[i] = frames[i][d]
+		}
+		result = append(result, columnFunctionals(column, percentiles)...)
+	}
+
+	return result
+}
+
+// FunctionalsMany computes the functionals for every matrix in x, e.g. for every file returned by ReadSpeechFeaturesAppend
+func FunctionalsMany(x [][][]float64, percentiles []float64) [][]float64 {
+	result := make([][]float64, len(x), len(x))
+	for i := range x {
+		result[i] = Functionals(x[i], percentiles)
+	}
+	return result
+}
+
+func columnFunctionals(x []float64, percentiles []float64) []float64 {
+	n := float64(len(x))
+
+	stats := statsOfValues(x)
+	mean := stats.Mean()[0]
+	m2 := stats.Variance()[0]
+
+	// the third and fourth central moments take a second pass (see RunningStats)
+	var m3, m4 float64
+	for _, v := range x {
+		d := v - mean
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m3 /= n
+	m4 /= n
+
+	std := math.Sqrt(m2)
+
+	// a constant signal has no shape, so we keep the moments finite
+	skewness := 0.0
+	kurtosis := 0.0
+	if m2 > EPS*EPS {
+		skewness = m3 / (m2 * std)
+		kurtosis = m4 / (m2 * m2)
+	}
+
+	sorted := make([]float64, len(x), len(x))
+	copy(sorted, x)
+	sort.Float64s(sorted)
+
+	min := sorted[0]
+	max := sorted[len(sorted)-1]
+
+	result := []float64{mean, std, skewness, kurtosis, min, max, max - min}
+	for _, p := range percentiles {
+		result = append(result, percentile(sorted, p))
+	}
+
+	return append(result, slope(x, mean), risingFraction(x))
+}
+
+// percentile returns the p-th (p in [0, 1]) percentile of the sorted x using linear interpolation between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		return sorted[0]
+	}
+	if upper >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// slope returns the slope of the least squares line through (i, x[i])
+func slope(x []float64, mean float64) float64 {
+	n := float64(len(x))
+	if len(x) < 2 {
+		return 0
+	}
+
+	tMean := (n - 1) / 2.0
+	var cov, tVar float64
+	for i, v := range x {
+		t := float64(i) - tMean
+		cov += t * (v - mean)
+		tVar += t * t
+	}
+
+	return cov / tVar
+}
+
+// risingFraction returns the fraction of consecutive frames in which the value increases
+func risingFraction(x []float64) float64 {
+	if len(x) < 2 {
+		return 0
+	}
+
+	rising := 0
+	for i := 1; i < len(x); i++ {
+		if x[i] > x[i-1] {
+			rising++
+		}
+	}
+
+	return float64(rising) / float64(len(x)-1)
+}
@@ -0,0 +1,42 @@
+package emotions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionals(t *testing.T) {
+	frames := [][]float64{
+		[]float64{1.0, 5.0},
+		[]float64{2.0, 5.0},
+		[]float64{3.0, 5.0},
+		[]float64{4.0, 5.0},
+		[]float64{5.0, 5.0},
+	}
+
+	percentiles := []float64{0.5, 1.0}
+	names := FunctionalNames(percentiles)
+	f := Functionals(frames, percentiles)
+	assert.Equal(t, 2*len(names), len(f))
+
+	rising := f[0:len(names)]
+	assert.InDelta(t, 3.0, rising[0], EPS)      // mean
+	assert.InDelta(t, 1.414213, rising[1], EPS) // std
+	assert.InDelta(t, 0.0, rising[2], EPS)      // skewness
+	assert.InDelta(t, 1.7, rising[3], EPS)      // kurtosis
+	assert.InDelta(t, 1.0, rising[4], EPS)      // min
+	assert.InDelta(t, 5.0, rising[5], EPS)      // max
+	assert.InDelta(t, 4.0, rising[6], EPS)      // range
+	assert.InDelta(t, 3.0, rising[7], EPS)      // p50
+	assert.InDelta(t, 5.0, rising[8], EPS)      // p100
+	assert.InDelta(t, 1.0, rising[9], EPS)      // slope
+	assert.InDelta(t, 1.0, rising[10], EPS)     // rising
+
+	constant := f[len(names):]
+	assert.InDelta(t, 5.0, constant[0], EPS)
+	assert.InDelta(t, 0.0, constant[1], EPS)
+	assert.InDelta(t, 0.0, constant[3], EPS)
+	assert.InDelta(t, 0.0, constant[9], EPS)
+	assert.InDelta(t, 0.0, constant[10], EPS)
+}
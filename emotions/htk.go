@@ -0,0 +1,141 @@
+package emotions
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// HTK parameter kinds
+const (
+	HTKWaveform  = 0
+	HTKLPC       = 1
+	HTKLPCepstra = 3
+	HTKMFCC      = 6
+	HTKFBank     = 7
+	HTKUser      = 9
+	HTKPLP       = 11
+)
+
+// HTK parameter kind qualifiers
+const (
+	HTKEnergy       = 0000100 // _E
+	HTKDelta        = 0000400 // _D
+	HTKAcceleration = 0001000 // _A
+	HTKCompressed   = 0002000 // _C
+	HTKZeroMean     = 0004000 // _Z
+	HTKChecksum     = 0010000 // _K
+	HTKC0           = 0020000 // _0
+)
+
+// HTKHeader is the 12 byte header of an HTK parameter file
+type HTKHeader struct {
+	Samples      int32
+	SamplePeriod int32 // in 100ns units
+	SampleSize   int16 // in bytes
+	ParmKind     int16
+}
+
+// NewHTKHeader returns the header for frames of the given dimension taken every stepInMs
+func NewHTKHeader(frames int, dimension int, stepInMs int, parmKind int16) HTKHeader {
+	return HTKHeader{
+		Samples:      int32(frames),
+		SamplePeriod: int32(stepInMs * 10000),
+		SampleSize:   int16(4 * dimension),
+		ParmKind:     parmKind,
+	}
+}
+
+// WriteHTKTo writes the features as an uncompressed big-endian HTK parameter file
+func WriteHTKTo(w io.Writer, features [][]float64, header HTKHeader) error {
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return err
+	}
+
+	for i := range features {
+		if 4*len(features[i]) != int(header.SampleSize) {
+			return fmt.Errorf("frame %d has %d coefficients, the header says %d", i, len(features[i]), header.SampleSize/4)
+		}
+
+		frame := make([]float32, len(features[i]), len(features[i]))
+		for j, v := range features[i] {
+			frame[j] = float32(v)
+		}
+		if err := binary.Write(w, binary.BigEndian, frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadHTKFrom reads an uncompressed big-endian HTK parameter file
+func ReadHTKFrom(r io.Reader) ([][]float64, HTKHeader, error) {
+	var header HTKHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, header, err
+	}
+
+	if header.ParmKind&HTKCompressed != 0 {
+		return nil, header, fmt.Errorf("compressed HTK files are not supported")
+	}
+	if header.SampleSize <= 0 || header.SampleSize%4 != 0 {
+		return nil, header, fmt.Errorf("unexpected HTK sample size: %d", header.SampleSize)
+	}
+
+	dimension := int(header.SampleSize / 4)
+	if err := checkMatrixShape(int(header.Samples), dimension); err != nil {
+		return nil, header, err
+	}
+	features := make([][]float64, header.Samples, header.Samples)
+	frame := make([]float32, dimension, dimension)
+	for i := range features {
+		if err := binary.Read(r, binary.BigEndian, frame); err != nil {
+			return nil, header, fmt.Errorf("frame %d: %s", i, err)
+		}
+
+		features[i] = make([]float64, dimension, dimension)
+		for j, v := range frame {
+			features[i][j] = float64(v)
+		}
+	}
+
+	return features, header, nil
+}
+
+// WriteHTK writes the features into an HTK parameter file
+func WriteHTK(filename string, features [][]float64, header HTKHeader) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	if err := WriteHTKTo(w, features, header); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadHTK reads the features from an HTK parameter file
+func ReadHTK(filename string) ([][]float64, HTKHeader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, HTKHeader{}, err
+	}
+	defer file.Close()
+
+	features, header, err := ReadHTKFrom(bufio.NewReader(file))
+	if err != nil {
+		return nil, header, fmt.Errorf("%s: %s", filename, err)
+	}
+	return features, header, nil
+}
@@ -0,0 +1,408 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ICAOptions are the parameters of FastICA and of the choice of the ocular components
+type ICAOptions struct {
+	// Components is the number of independent components, 0 means one per channel
+	Components int
+	MaxIter    int
+	Tolerance  float64
+	Seed       int64
+	// FrontalChannels are the channels the components are correlated with, the missing ones are skipped
+	FrontalChannels []string
+	// FrontalCorrelation is the smallest absolute correlation with a frontal channel of an ocular component
+	FrontalCorrelation float64
+	// Kurtosis is the smallest excess kurtosis of an ocular component (blinks are rare and large)
+	Kurtosis float64
+	// RemoveOcular turns on the removal of the ocular components in GetFourierForFile
+	RemoveOcular bool
+}
+
+// DefaultICAOptions returns the options for the headset, with the removal turned off
+func DefaultICAOptions() ICAOptions {
+	return ICAOptions{
+		MaxIter:            200,
+		Tolerance:          1e-6,
+		Seed:               1,
+		FrontalChannels:    []string{"Fp1", "Fp2", "Fpz"},
+		FrontalCorrelation: 0.6,
+		Kurtosis:           1,
+	}
+}
+
+func (o ICAOptions) String() string {
+	return fmt.Sprintf("components=%d iter=%d tol=%g seed=%d frontal=%v/%g kurtosis=%g remove=%t",
+		o.Components, o.MaxIter, o.Tolerance, o.Seed, o.FrontalChannels, o.FrontalCorrelation, o.Kurtosis, o.RemoveOcular)
+}
+
+// EEGICA are the options GetFourierForFile removes the ocular components with
+var EEGICA = DefaultICAOptions()
+
+// ICA is the decomposition of channels x samples data into independent components
+// sources = Unmixing (data - Mean) and data = Mixing sources + Mean
+type ICA struct {
+	// Mean is the mean of every channel
+	Mean []float64
+	// Unmixing is components x channels
+	Unmixing [][]float64
+	// Mixing is channels x components, its columns are the scalp maps of the components
+	Mixing [][]float64
+	// Iterations is the number of FastICA iterations until convergence
+	Iterations int
+}
+
+func transposed(a [][]float64) [][]float64 {
+	if len(a) == 0 {
+		return nil
+	}
+
+	t := make([][]float64, len(a[0]), len(a[0]))
+	for j := range t {
+		t[j] = make([]float64, len(a), len(a))
+		for i := range a {
+			t[j][i] = a[i][j]
+		}
+	}
+	return t
+}
+
+func multiplyMatrices(a [][]float64, b [][]float64) [][]float64 {
+	product := make([][]float64, len(a), len(a))
+	for i := range a {
+		product[i] = make([]float64, len(b[0]), len(b[0]))
+		for k := range b {
+			if a[i][k] == 0 {
+				continue
+			}
+			for j := range b[k] {
+				product[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return product
+}
+
+// symmetricEigen diagonalises the symmetric matrix with Jacobi rotations
+// and returns the eigenvalues in decreasing order and the eigenvectors as the columns of a matrix
+func symmetricEigen(matrix [][]float64) ([]float64, [][]float64) {
+	n := len(matrix)
+	a := make([][]float64, n, n)
+	v := make([][]float64, n, n)
+	for i := range a {
+		a[i] = append([]float64{}, matrix[i]...)
+		v[i] = make([]float64, n, n)
+		v[i][i] = 1
+	}
+
+	for sweep := 0; sweep < 100; sweep++ {
+		var off float64
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-22 {
+			break
+		}
+
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if math.Abs(a[p][q]) < 1e-300 {
+					continue
+				}
+
+				θ := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := math.Copysign(1, θ) / (math.Abs(θ) + math.Sqrt(θ*θ+1))
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				for k := 0; k < n; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < n; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	order := make([]int, n, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return a[order[i]][order[i]] > a[order[j]][order[j]] })
+
+	values := make([]float64, n, n)
+	vectors := make([][]float64, n, n)
+	for i := range vectors {
+		vectors[i] = make([]float64, n, n)
+	}
+	for j, o := range order {
+		values[j] = a[o][o]
+		for i := 0; i < n; i++ {
+			vectors[i][j] = v[i][o]
+		}
+	}
+	return values, vectors
+}
+
+// decorrelate makes the rows of w orthonormal: w = (w wᵀ)^(-1/2) w
+func decorrelate(w [][]float64) [][]float64 {
+	values, vectors := symmetricEigen(multiplyMatrices(w, transposed(w)))
+	scaled := make([][]float64, len(vectors), len(vectors))
+	for i := range vectors {
+		scaled[i] = make([]float64, len(values), len(values))
+		for j := range values {
+			scaled[i][j] = vectors[i][j] / math.Sqrt(math.Max(values[j], EPS))
+		}
+	}
+	return multiplyMatrices(multiplyMatrices(scaled, transposed(vectors)), w)
+}
+
+// FastICA decomposes the channels x samples data into independent components
+// The data is centered and whitened with the principal components, then the components are found together
+// with the symmetric FastICA fixed point iteration and the logcosh contrast
+func FastICA(data [][]float64, options ICAOptions) (ICA, error) {
+	channels := len(data)
+	if channels == 0 || len(data[0]) < 2 {
+		return ICA{}, fmt.Errorf("not enough data for ica: %d channels", channels)
+	}
+	samples := len(data[0])
+
+	components := options.Components
+	if components == 0 {
+		components = channels
+	}
+	if components < 0 || components > channels {
+		return ICA{}, fmt.Errorf("%d components for %d channels", components, channels)
+	}
+
+	for i := range data {
+		if len(data[i]) != samples {
+			return ICA{}, fmt.Errorf("channel %d has %d samples instead of %d", i, len(data[i]), samples)
+		}
+	}
+
+	stats := NewRunningStats(channels, true)
+	for _, sample := range transposed(data) {
+		stats.Add(sample)
+	}
+	mean := stats.Mean()
+	covariance := stats.Covariance()
+
+	centered := make([][]float64, channels, channels)
+	for i := range data {
+		centered[i] = make([]float64, samples, samples)
+		for t, x := range data[i] {
+			centered[i][t] = x - mean[i]
+		}
+	}
+
+	values, vectors := symmetricEigen(covariance)
+	if values[components-1] < EPS {
+		return ICA{}, fmt.Errorf("the data has rank less than %d components", components)
+	}
+
+	// whitening is components x channels and dewhitening channels x components
+	whitening := make([][]float64, components, components)
+	dewhitening := make([][]float64, channels, channels)
+	for c := 0; c < components; c++ {
+		whitening[c] = make([]float64, channels, channels)
+		for i := 0; i < channels; i++ {
+			whitening[c][i] = vectors[i][c] / math.Sqrt(values[c])
+		}
+	}
+	for i := 0; i < channels; i++ {
+		dewhitening[i] = make([]float64, components, components)
+		for c := 0; c < components; c++ {
+			dewhitening[i][c] = vectors[i][c] * math.Sqrt(values[c])
+		}
+	}
+	white := multiplyMatrices(whitening, centered)
+
+	random := rand.New(rand.NewSource(options.Seed))
+	w := make([][]float64, components, components)
+	for c := range w {
+		w[c] = make([]float64, components, components)
+		for j := range w[c] {
+			w[c][j] = random.NormFloat64()
+		}
+	}
+	w = decorrelate(w)
+
+	iteration := 0
+	for iteration < options.MaxIter {
+		iteration++
+
+		projections := multiplyMatrices(w, white)
+		updated := make([][]float64, components, components)
+		for c := range updated {
+			updated[c] = make([]float64, components, components)
+			var derivative float64
+			for t, y := range projections[c] {
+				g := math.Tanh(y)
+				derivative += 1 - g*g
+				for j := range updated[c] {
+					updated[c][j] += white[j][t] * g
+				}
+			}
+			for j := range updated[c] {
+				updated[c][j] = (updated[c][j] - derivative*w[c][j]) / float64(samples)
+			}
+		}
+		updated = decorrelate(updated)
+
+		var change float64
+		for c := range w {
+			var d float64
+			for j := range w[c] {
+				d += w[c][j] * updated[c][j]
+			}
+			change = math.Max(change, math.Abs(math.Abs(d)-1))
+		}
+
+		w = updated
+		if change < options.Tolerance {
+			break
+		}
+	}
+
+	return ICA{
+		Mean:       mean,
+		Unmixing:   multiplyMatrices(w, whitening),
+		Mixing:     multiplyMatrices(dewhitening, transposed(w)),
+		Iterations: iteration,
+	}, nil
+}
+
+// Sources returns the components x samples activations of the components in the data
+func (ica ICA) Sources(data [][]float64) [][]float64 {
+	centered := make([][]float64, len(data), len(data))
+	for i := range data {
+		centered[i] = make([]float64, len(data[i]), len(data[i]))
+		for t, x := range data[i] {
+			centered[i][t] = x - ica.Mean[i]
+		}
+	}
+	return multiplyMatrices(ica.Unmixing, centered)
+}
+
+// RemoveComponents returns the data reconstructed from all the components but the given ones
+// What the components don't explain (with fewer components than channels) is lost
+func (ica ICA) RemoveComponents(data [][]float64, components []int) [][]float64 {
+	sources := ica.Sources(data)
+	for _, c := range components {
+		zero(&sources[c])
+	}
+
+	reconstructed := multiplyMatrices(ica.Mixing, sources)
+	for i := range reconstructed {
+		for t := range reconstructed[i] {
+			reconstructed[i][t] += ica.Mean[i]
+		}
+	}
+	return reconstructed
+}
+
+// excessKurtosis returns m4 / m2² - 3, which is 0 for a gaussian and large for rare large peaks like blinks
+func excessKurtosis(x []float64) float64 {
+	stats := statsOfValues(x)
+	mean := stats.Mean()[0]
+	m2 := stats.Variance()[0]
+	if m2 == 0 {
+		return 0
+	}
+
+	// the fourth moment takes a second pass (see RunningStats)
+	var m4 float64
+	for _, v := range x {
+		d := (v - mean) * (v - mean)
+		m4 += d * d
+	}
+	m4 /= float64(len(x))
+	return m4/(m2*m2) - 3
+}
+
+func pearsonCorrelation(x []float64, y []float64) float64 {
+	stats := NewRunningStats(2, true)
+	pair := make([]float64, 2, 2)
+	for i := range x {
+		pair[0], pair[1] = x[i], y[i]
+		stats.Add(pair)
+	}
+
+	covariance := stats.Covariance()
+	if covariance[0][0] == 0 || covariance[1][1] == 0 {
+		return 0
+	}
+	return covariance[0][1] / math.Sqrt(covariance[0][0]*covariance[1][1])
+}
+
+// ComponentKurtosis returns the excess kurtosis of every component
+func ComponentKurtosis(sources [][]float64) []float64 {
+	kurtosis := make([]float64, len(sources), len(sources))
+	for c := range sources {
+		kurtosis[c] = excessKurtosis(sources[c])
+	}
+	return kurtosis
+}
+
+// FrontalCorrelation returns for every component the largest absolute correlation with one of the frontal channels of the recording
+func FrontalCorrelation(sources [][]float64, rec EEGRecording, frontal []string) []float64 {
+	correlations := make([]float64, len(sources), len(sources))
+	for _, label := range frontal {
+		f := findChannel(rec, label)
+		if f == -1 {
+			continue
+		}
+		for c := range sources {
+			correlations[c] = math.Max(correlations[c], math.Abs(pearsonCorrelation(sources[c], rec.Data[f])))
+		}
+	}
+	return correlations
+}
+
+// OcularComponents returns the components which are both correlated with the frontal channels and peaky enough
+func OcularComponents(ica ICA, rec EEGRecording, options ICAOptions) []int {
+	sources := ica.Sources(rec.Data)
+	kurtosis := ComponentKurtosis(sources)
+	correlations := FrontalCorrelation(sources, rec, options.FrontalChannels)
+
+	var ocular []int
+	for c := range sources {
+		if correlations[c] >= options.FrontalCorrelation && kurtosis[c] >= options.Kurtosis {
+			ocular = append(ocular, c)
+		}
+	}
+	return ocular
+}
+
+// RemoveOcularComponents decomposes the recording with FastICA and returns it without the ocular components
+// together with the indices of the removed components
+func RemoveOcularComponents(rec EEGRecording, options ICAOptions) (EEGRecording, []int, error) {
+	ica, err := FastICA(rec.Data, options)
+	if err != nil {
+		return EEGRecording{}, nil, err
+	}
+
+	ocular := OcularComponents(ica, rec, options)
+	if len(ocular) == 0 {
+		return rec, nil, nil
+	}
+	return withChannels(rec, rec.Channels, rec.Units, ica.RemoveComponents(rec.Data, ocular)), ocular, nil
+}
@@ -0,0 +1,50 @@
+package emotions
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFastICA(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	samples := 5000
+	alpha := make([]float64, samples, samples)
+	blinks := make([]float64, samples, samples)
+	noise := make([]float64, samples, samples)
+	for i := 0; i < samples; i++ {
+		alpha[i] = math.Sin(2 * math.Pi * 10 * float64(i) / 500)
+		noise[i] = random.Float64()*2 - 1
+		if i%1000 < 100 {
+			blinks[i] = 8 * math.Sin(math.Pi*float64(i%1000)/100)
+		}
+	}
+
+	// the blinks are strongest on Fp1 and barely reach O1
+	mixing := [][]float64{{1, 1, 0.2}, {1, 0.3, 0.5}, {1, 0.05, 0.3}}
+	data := multiplyMatrices(mixing, [][]float64{alpha, blinks, noise})
+	rec, err := NewEEGRecording(data, 500, []string{"Fp1", "Cz", "O1"}, nil)
+	assert.Nil(t, err)
+
+	options := DefaultICAOptions()
+	ica, err := FastICA(rec.Data, options)
+	assert.Nil(t, err)
+	assert.True(t, ica.Iterations < options.MaxIter)
+
+	// without removing anything the data is reconstructed
+	reconstructed := ica.RemoveComponents(rec.Data, nil)
+	for i := range data {
+		for j := 0; j < samples; j += 100 {
+			assert.InDelta(t, data[i][j], reconstructed[i][j], 1e-6)
+		}
+	}
+
+	cleaned, removed, err := RemoveOcularComponents(rec, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(removed))
+	for i := range data {
+		assert.True(t, math.Abs(pearsonCorrelation(cleaned.Data[i], blinks)) < 0.05)
+	}
+}
@@ -0,0 +1,350 @@
+package emotions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// IndexKind is the kind of tree of a NeighbourIndex
+type IndexKind int
+
+const (
+	// KDTree splits the space by the median of the coordinate with the largest spread, which prunes well in few dimensions
+	KDTree IndexKind = iota
+	// VPTree splits the points by their distance to a vantage point, which still prunes in many dimensions
+	VPTree
+)
+
+func (k IndexKind) String() string {
+	switch k {
+	case KDTree:
+		return "kd-tree"
+	case VPTree:
+		return "vp-tree"
+	default:
+		return fmt.Sprintf("IndexKind(%d)", int(k))
+	}
+}
+
+// kdTreeMaxDimension is the largest dimension NewNeighbourIndex builds a KD-tree for
+const kdTreeMaxDimension = 16
+
+// Neighbour is a vector of the index found by a query
+type Neighbour struct {
+	// Index is the position of the vector among all the vectors of the tagged sets in order
+	Index int
+	Tag   string
+	// Distance is the euclidean distance with every coordinate divided by its standard deviation
+	// i.e. the square root of mahalanobisDistance
+	Distance float64
+}
+
+// indexNode is a point of the tree with the points on its two sides
+type indexNode struct {
+	Point int
+	// Axis is the coordinate a KD-tree node splits by
+	Axis int
+	// Threshold is the coordinate of the point in a KD-tree or the median distance to it in a VP-tree
+	Threshold float64
+	// Left is the node of the points below the threshold and Right of the rest, -1 if there are none
+	Left  int
+	Right int
+}
+
+// NeighbourIndex finds the nearest tagged vectors with variance scaled distances without scanning all of them
+// It prunes well when the vectors lie close to a few states, but on unstructured data in many dimensions it gets close to a scan
+type NeighbourIndex struct {
+	Kind      IndexKind
+	Variances []float64
+	// Points are the vectors divided by the standard deviations, so the distances are euclidean
+	Points [][]float64
+	Tags   []string
+	Nodes  []indexNode
+	Root   int
+}
+
+// NewNeighbourIndex builds a KD-tree for vectors of up to 16 coordinates and a VP-tree for more
+// The distances are scaled by the variances, e.g. those of GetμAndσTagged
+func NewNeighbourIndex(tagged []Tagged, variances []float64) (*NeighbourIndex, error) {
+	if len(variances) <= kdTreeMaxDimension {
+		return NewIndex(KDTree, tagged, variances)
+	}
+	return NewIndex(VPTree, tagged, variances)
+}
+
+// NewIndex builds an index of the given kind
+func NewIndex(kind IndexKind, tagged []Tagged, variances []float64) (*NeighbourIndex, error) {
+	if kind != KDTree && kind != VPTree {
+		return nil, fmt.Errorf("unknown index %s", kind)
+	}
+
+	scale := append([]float64{}, variances...)
+	getSqrt(&scale)
+	index := &NeighbourIndex{Kind: kind, Variances: append([]float64{}, variances...)}
+	for _, t := range tagged {
+		for _, v := range t.Data {
+			if len(v) != len(variances) {
+				return nil, fmt.Errorf("vector %d of %s has %d coordinates instead of %d", len(index.Points), t.Tag, len(v), len(variances))
+			}
+			point := make([]float64, len(v), len(v))
+			for j := range v {
+				point[j] = v[j] / scale[j]
+			}
+			index.Points = append(index.Points, point)
+			index.Tags = append(index.Tags, t.Tag)
+		}
+	}
+	if len(index.Points) == 0 {
+		return nil, fmt.Errorf("no vectors to index")
+	}
+
+	points := make([]int, len(index.Points), len(index.Points))
+	for i := range points {
+		points[i] = i
+	}
+	if kind == KDTree {
+		index.Root = index.buildKD(points)
+	} else {
+		index.Root = index.buildVP(points)
+	}
+	return index, nil
+}
+
+func (index *NeighbourIndex) buildKD(points []int) int {
+	if len(points) == 0 {
+		return -1
+	}
+
+	// the coordinate with the largest spread
+	axis := 0
+	spread := -1.0
+	for j := range index.Points[0] {
+		low, high := math.Inf(1), math.Inf(-1)
+		for _, p := range points {
+			low = math.Min(low, index.Points[p][j])
+			high = math.Max(high, index.Points[p][j])
+		}
+		if high-low > spread {
+			spread = high - low
+			axis = j
+		}
+	}
+
+	sort.SliceStable(points, func(a, b int) bool { return index.Points[points[a]][axis] < index.Points[points[b]][axis] })
+	median := len(points) / 2
+	// the points equal to the median go right
+	for median > 0 && index.Points[points[median-1]][axis] == index.Points[points[median]][axis] {
+		median--
+	}
+
+	node := len(index.Nodes)
+	index.Nodes = append(index.Nodes, indexNode{Point: points[median], Axis: axis, Threshold: index.Points[points[median]][axis]})
+	left := index.buildKD(points[:median])
+	right := index.buildKD(points[median+1:])
+	index.Nodes[node].Left, index.Nodes[node].Right = left, right
+	return node
+}
+
+// byDistance sorts the points by their distances
+type byDistance struct {
+	points    []int
+	distances []float64
+}
+
+func (b byDistance) Len() int           { return len(b.points) }
+func (b byDistance) Less(i, j int) bool { return b.distances[i] < b.distances[j] }
+func (b byDistance) Swap(i, j int) {
+	b.points[i], b.points[j] = b.points[j], b.points[i]
+	b.distances[i], b.distances[j] = b.distances[j], b.distances[i]
+}
+
+func (index *NeighbourIndex) buildVP(points []int) int {
+	if len(points) == 0 {
+		return -1
+	}
+
+	vantage := points[0]
+	rest := points[1:]
+	distances := make([]float64, len(rest), len(rest))
+	for i, p := range rest {
+		distances[i] = index.distance(index.Points[vantage], p)
+	}
+	sort.Sort(byDistance{points: rest, distances: distances})
+
+	median := len(rest) / 2
+	for median > 0 && distances[median-1] == distances[median] {
+		median--
+	}
+	threshold := 0.0
+	if len(rest) > 0 {
+		threshold = distances[median]
+	}
+
+	node := len(index.Nodes)
+	index.Nodes = append(index.Nodes, indexNode{Point: vantage, Threshold: threshold})
+	left := index.buildVP(rest[:median])
+	right := index.buildVP(rest[median:])
+	index.Nodes[node].Left, index.Nodes[node].Right = left, right
+	return node
+}
+
+// distance returns the euclidean distance between the scaled query and a point of the index
+func (index *NeighbourIndex) distance(query []float64, point int) float64 {
+	return math.Sqrt(euclidianDistance(query, index.Points[point], nil))
+}
+
+func (index *NeighbourIndex) scaled(v []float64) []float64 {
+	if len(v) != len(index.Variances) {
+		panic(fmt.Sprintf("the query has %d coordinates instead of %d", len(v), len(index.Variances)))
+	}
+	query := make([]float64, len(v), len(v))
+	for j := range v {
+		query[j] = v[j] / math.Sqrt(index.Variances[j])
+	}
+	return query
+}
+
+// neighbours keeps the k closest points found so far in increasing distance, or all the points within the radius if k is 0
+type neighbours struct {
+	k      int
+	radius float64
+	found  []Neighbour
+}
+
+// bound returns the distance beyond which no point is wanted
+func (n *neighbours) bound() float64 {
+	if n.k > 0 && len(n.found) == n.k {
+		return n.found[n.k-1].Distance
+	}
+	return n.radius
+}
+
+func (n *neighbours) add(neighbour Neighbour) {
+	if neighbour.Distance > n.bound() || (n.k > 0 && len(n.found) == n.k && neighbour.Distance == n.bound()) {
+		return
+	}
+	at := sort.Search(len(n.found), func(i int) bool { return n.found[i].Distance > neighbour.Distance })
+	n.found = append(n.found, Neighbour{})
+	copy(n.found[at+1:], n.found[at:])
+	n.found[at] = neighbour
+	if n.k > 0 && len(n.found) > n.k {
+		n.found = n.found[:n.k]
+	}
+}
+
+func (index *NeighbourIndex) search(node int, query []float64, result *neighbours) {
+	if node == -1 {
+		return
+	}
+
+	n := index.Nodes[node]
+	d := index.distance(query, n.Point)
+	result.add(Neighbour{Index: n.Point, Tag: index.Tags[n.Point], Distance: d})
+
+	// the distance from the query to the other side of the split, which it has to be within to hold closer points
+	var near, far int
+	var gap float64
+	if index.Kind == KDTree {
+		diff := query[n.Axis] - n.Threshold
+		near, far, gap = n.Left, n.Right, math.Abs(diff)
+		if diff >= 0 {
+			near, far = n.Right, n.Left
+		}
+		index.search(near, query, result)
+		if gap <= result.bound() {
+			index.search(far, query, result)
+		}
+		return
+	}
+
+	// the points of the left are closer to the vantage point than the threshold and those of the right farther
+	if d < n.Threshold {
+		index.search(n.Left, query, result)
+		if n.Threshold-d <= result.bound() {
+			index.search(n.Right, query, result)
+		}
+		return
+	}
+	index.search(n.Right, query, result)
+	if d-n.Threshold <= result.bound() {
+		index.search(n.Left, query, result)
+	}
+}
+
+// Nearest returns the k vectors closest to v in increasing distance
+func (index *NeighbourIndex) Nearest(v []float64, k int) []Neighbour {
+	if k < 1 {
+		return nil
+	}
+	result := &neighbours{k: k, radius: math.Inf(1)}
+	index.search(index.Root, index.scaled(v), result)
+	return result.found
+}
+
+// Within returns all the vectors at most radius away from v in increasing distance
+func (index *NeighbourIndex) Within(v []float64, radius float64) []Neighbour {
+	result := &neighbours{radius: radius}
+	index.search(index.Root, index.scaled(v), result)
+	return result.found
+}
+
+// Save writes the index as json, so it is built once for a training set
+// Like MiniBatchKMeans.Save it replaces the file only after it is fully written
+func (index *NeighbourIndex) Save(filename string) error {
+	bytes, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return replaceFile(filename, bytes)
+}
+
+// LoadNeighbourIndex reads an index written by Save
+func LoadNeighbourIndex(filename string) (*NeighbourIndex, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var index NeighbourIndex
+	if err := json.Unmarshal(bytes, &index); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if len(index.Points) != len(index.Tags) || len(index.Nodes) != len(index.Points) || index.Root < 0 || index.Root >= len(index.Nodes) {
+		return nil, fmt.Errorf("%s: inconsistent index of %d points, %d tags and %d nodes", filename, len(index.Points), len(index.Tags), len(index.Nodes))
+	}
+	if err := index.check(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return &index, nil
+}
+
+// check makes sure that a search can't go out of the points and nodes or loop
+// The nodes are built depth first, so the children of a node always come after it
+func (index *NeighbourIndex) check() error {
+	if index.Kind != KDTree && index.Kind != VPTree {
+		return fmt.Errorf("unknown index %s", index.Kind)
+	}
+	for i, p := range index.Points {
+		if len(p) != len(index.Variances) {
+			return fmt.Errorf("point %d has %d coordinates instead of %d", i, len(p), len(index.Variances))
+		}
+	}
+
+	for i, n := range index.Nodes {
+		if n.Point < 0 || n.Point >= len(index.Points) {
+			return fmt.Errorf("node %d has point %d of %d", i, n.Point, len(index.Points))
+		}
+		if index.Kind == KDTree && (n.Axis < 0 || n.Axis >= len(index.Variances)) {
+			return fmt.Errorf("node %d splits by coordinate %d of %d", i, n.Axis, len(index.Variances))
+		}
+		for _, child := range []int{n.Left, n.Right} {
+			if child != -1 && (child <= i || child >= len(index.Nodes)) {
+				return fmt.Errorf("node %d has child %d of %d nodes", i, child, len(index.Nodes))
+			}
+		}
+	}
+	return nil
+}
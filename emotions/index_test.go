@@ -0,0 +1,151 @@
+package emotions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomTagged(random *rand.Rand, tags int, n int, dim int) []Tagged {
+	tagged := make([]Tagged, tags, tags)
+	for t := range tagged {
+		tagged[t].Tag = fmt.Sprintf("tag%d", t)
+		tagged[t].Data = make([][]float64, n, n)
+		for i := range tagged[t].Data {
+			tagged[t].Data[i] = make([]float64, dim, dim)
+			for j := range tagged[t].Data[i] {
+				// the coordinates have different scales and some ties
+				tagged[t].Data[i][j] = math.Round(random.NormFloat64()*10) * float64(j+1)
+			}
+		}
+	}
+	return tagged
+}
+
+// bruteForce returns the distances of all the vectors to v in increasing order
+func bruteForce(tagged []Tagged, variances []float64, v []float64) []float64 {
+	var distances []float64
+	for _, t := range tagged {
+		for _, x := range t.Data {
+			distances = append(distances, math.Sqrt(mahalanobisDistance(v, x, variances)))
+		}
+	}
+	sort.Float64s(distances)
+	return distances
+}
+
+func TestNeighbourIndex(t *testing.T) {
+	random := rand.New(rand.NewSource(4))
+	for _, dim := range []int{3, 40} {
+		tagged := randomTagged(random, 3, 200, dim)
+		_, variances := GetμAndσTagged(tagged)
+
+		for _, kind := range []IndexKind{KDTree, VPTree} {
+			index, err := NewIndex(kind, tagged, variances)
+			assert.Nil(t, err)
+
+			for q := 0; q < 20; q++ {
+				v := randomTagged(random, 1, 1, dim)[0].Data[0]
+				expected := bruteForce(tagged, variances, v)
+
+				nearest := index.Nearest(v, 5)
+				assert.Len(t, nearest, 5)
+				for i, n := range nearest {
+					assert.InDelta(t, expected[i], n.Distance, 1e-9, "%s %d", kind, dim)
+				}
+
+				// away from the boundary, where the rounding of the scaled points decides
+				radius := (expected[10] + expected[11]) / 2
+				within := index.Within(v, radius)
+				count := sort.Search(len(expected), func(i int) bool { return expected[i] > radius })
+				assert.Len(t, within, count, "%s %d", kind, dim)
+			}
+		}
+	}
+}
+
+func TestNeighbourIndexSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "index")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	tagged := randomTagged(rand.New(rand.NewSource(2)), 2, 50, 20)
+	_, variances := GetμAndσTagged(tagged)
+	index, err := NewNeighbourIndex(tagged, variances)
+	assert.Nil(t, err)
+	assert.Equal(t, VPTree, index.Kind)
+
+	filename := filepath.Join(dir, "index.json")
+	assert.Nil(t, index.Save(filename))
+	loaded, err := LoadNeighbourIndex(filename)
+	assert.Nil(t, err)
+
+	v := tagged[1].Data[7]
+	assert.Equal(t, index.Nearest(v, 3), loaded.Nearest(v, 3))
+	assert.Equal(t, "tag1", loaded.Nearest(v, 1)[0].Tag)
+	assert.Equal(t, 57, loaded.Nearest(v, 1)[0].Index)
+
+	// a truncated or edited file is rejected instead of panicking in Nearest
+	bytes, err := ioutil.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filename, bytes[:len(bytes)/2], 0644))
+	_, err = LoadNeighbourIndex(filename)
+	assert.NotNil(t, err)
+
+	for _, edit := range []func(*NeighbourIndex){
+		func(index *NeighbourIndex) { index.Nodes[3].Point = len(index.Points) },
+		func(index *NeighbourIndex) { index.Nodes[3].Left = len(index.Nodes) },
+		func(index *NeighbourIndex) { index.Nodes[3].Right = index.Root },
+		func(index *NeighbourIndex) { index.Points[3] = index.Points[3][1:] },
+	} {
+		edited, err := NewNeighbourIndex(tagged, variances)
+		assert.Nil(t, err)
+		edit(edited)
+		assert.Nil(t, edited.Save(filename))
+		_, err = LoadNeighbourIndex(filename)
+		assert.NotNil(t, err)
+	}
+}
+
+func BenchmarkNeighbourIndex(b *testing.B) {
+	// frames of real recordings lie close to a few states, unlike uniform noise where no tree can prune
+	random := rand.New(rand.NewSource(1))
+	points := syntheticClusters(random, 20000, 20, 76)
+	tagged := make([]Tagged, 4, 4)
+	for i, p := range points {
+		tagged[i%4].Tag = fmt.Sprintf("tag%d", i%4)
+		tagged[i%4].Data = append(tagged[i%4].Data, p)
+	}
+	_, variances := GetμAndσTagged(tagged)
+	queries := make([][]float64, 100, 100)
+	for i := range queries {
+		queries[i] = append([]float64{}, points[random.Intn(len(points))]...)
+		for j := range queries[i] {
+			queries[i][j] += random.NormFloat64()
+		}
+	}
+
+	index, _ := NewIndex(VPTree, tagged, variances)
+	b.Run("vp-tree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.Nearest(queries[i%len(queries)], 1)
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			closest := math.Inf(1)
+			for _, t := range tagged {
+				for _, x := range t.Data {
+					closest = math.Min(closest, mahalanobisDistance(queries[i%len(queries)], x, variances))
+				}
+			}
+		}
+	})
+}
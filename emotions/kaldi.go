@@ -0,0 +1,272 @@
+package emotions
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// writeKaldiMatrix writes a single matrix in the kaldi binary format (without the key)
+// \0B FM <4><rows> <4><cols> followed by little-endian float32 data
+func writeKaldiMatrix(w io.Writer, features [][]float64) error {
+	rows := int32(len(features))
+	cols := int32(0)
+	if rows > 0 {
+		cols = int32(len(features[0]))
+	}
+
+	if _, err := w.Write([]byte("\x00BFM ")); err != nil {
+		return err
+	}
+	for _, n := range []int32{rows, cols} {
+		if _, err := w.Write([]byte{4}); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, n); err != nil {
+			return err
+		}
+	}
+
+	row := make([]float32, cols, cols)
+	for i := range features {
+		if int32(len(features[i])) != cols {
+			return fmt.Errorf("row %d has %d columns instead of %d", i, len(features[i]), cols)
+		}
+		for j, v := range features[i] {
+			row[j] = float32(v)
+		}
+		if err := binary.Write(w, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readKaldiInt32(r io.Reader) (int32, error) {
+	size := make([]byte, 1, 1)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return 0, err
+	}
+	if size[0] != 4 {
+		return 0, fmt.Errorf("expected a 4 byte integer, got size %d", size[0])
+	}
+
+	var n int32
+	err := binary.Read(r, binary.LittleEndian, &n)
+	return n, err
+}
+
+// readKaldiMatrix reads a single binary float (FM) or double (DM) matrix starting at the \0B marker
+func readKaldiMatrix(r io.Reader) ([][]float64, error) {
+	token := make([]byte, 5, 5)
+	if _, err := io.ReadFull(r, token); err != nil {
+		return nil, err
+	}
+
+	if token[0] != 0 || token[1] != 'B' {
+		return nil, fmt.Errorf("only binary kaldi archives are supported")
+	}
+
+	double := false
+	switch string(token[2:]) {
+	case "FM ":
+	case "DM ":
+		double = true
+	default:
+		return nil, fmt.Errorf("unsupported kaldi matrix type %q", string(token[2:]))
+	}
+
+	rows, err := readKaldiInt32(r)
+	if err != nil {
+		return nil, err
+	}
+	cols, err := readKaldiInt32(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMatrixShape(int(rows), int(cols)); err != nil {
+		return nil, err
+	}
+
+	features := make([][]float64, rows, rows)
+	for i := range features {
+		features[i] = make([]float64, cols, cols)
+		if double {
+			err = binary.Read(r, binary.LittleEndian, features[i])
+		} else {
+			row := make([]float32, cols, cols)
+			err = binary.Read(r, binary.LittleEndian, row)
+			for j, v := range row {
+				features[i][j] = float64(v)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", i, err)
+		}
+	}
+
+	return features, nil
+}
+
+// WriteKaldi writes every tagged matrix into a binary kaldi archive with the tag as its key
+// and an scp file which points to the matrices in the archive
+func WriteKaldi(arkFilename string, scpFilename string, features []Tagged) error {
+	ark, err := os.Create(arkFilename)
+	if err != nil {
+		return err
+	}
+
+	scp, err := os.Create(scpFilename)
+	if err != nil {
+		ark.Close()
+		return err
+	}
+
+	err = writeKaldi(ark, scp, arkFilename, features)
+	// a full disk may only show up when the files are closed
+	if closeErr := ark.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := scp.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeKaldi writes the archive and the scp through buffers and checks that both are flushed
+func writeKaldi(ark io.Writer, scp io.Writer, arkFilename string, features []Tagged) error {
+	buffered := bufio.NewWriter(ark)
+	bufferedScp := bufio.NewWriter(scp)
+	w := &countingWriter{w: buffered}
+	for _, f := range features {
+		if strings.ContainsAny(f.Tag, " \t\n") || f.Tag == "" {
+			return fmt.Errorf("invalid kaldi key: %q", f.Tag)
+		}
+
+		if _, err := io.WriteString(w, f.Tag+" "); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(bufferedScp, "%s %s:%d\n", f.Tag, arkFilename, w.n); err != nil {
+			return err
+		}
+
+		if err := writeKaldiMatrix(w, f.Data); err != nil {
+			return fmt.Errorf("%s: %s", f.Tag, err)
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return bufferedScp.Flush()
+}
+
+// countingWriter keeps the number of bytes written so far, which are the offsets in the scp file
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadKaldiArk reads all the matrices in a binary kaldi archive
+func ReadKaldiArk(filename string) ([]Tagged, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	var features []Tagged
+	for {
+		key, err := r.ReadString(' ')
+		if err == io.EOF && strings.TrimSpace(key) == "" {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+
+		data, err := readKaldiMatrix(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", filename, key, err)
+		}
+
+		features = append(features, Tagged{
+			Tag:  strings.TrimSpace(key),
+			Data: data,
+		})
+	}
+
+	return features, nil
+}
+
+// ReadKaldiScp reads the matrices listed in a kaldi scp file (lines of key ark:offset)
+func ReadKaldiScp(filename string) ([]Tagged, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var features []Tagged
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected <key> <ark>:<offset>", filename, line)
+		}
+
+		colon := strings.LastIndex(fields[1], ":")
+		if colon == -1 {
+			return nil, fmt.Errorf("%s:%d: missing offset in %s", filename, line, fields[1])
+		}
+		offset, err := strconv.ParseInt(fields[1][colon+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+
+		data, err := readKaldiMatrixAt(fields[1][:colon], offset)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+
+		features = append(features, Tagged{
+			Tag:  fields[0],
+			Data: data,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+func readKaldiMatrixAt(filename string, offset int64) ([][]float64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return readKaldiMatrix(bufio.NewReader(file))
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"os"
 	"time"
 )
 
@@ -20,223 +19,530 @@ func (m MfccClusterisable) GetCluster() int32 {
 	return m.clusterID
 }
 
+// KMeansInit is the way the first centroids of KMeans are chosen
+type KMeansInit int
+
+const (
+	// InitRandom picks k different random points
+	InitRandom KMeansInit = iota
+	// InitKMeansPP picks a random point and then every next one with probability proportional to
+	// its squared distance to the closest centroid so far (k-means++)
+	InitKMeansPP
+	// InitFarthestPoint picks a random point and then always the one farthest from the centroids so far
+	// It is deterministic after the first point, but it tends to pick outliers
+	InitFarthestPoint
+	// InitGiven starts from KMeansOptions.Centroids
+	InitGiven
+)
+
+func (i KMeansInit) String() string {
+	switch i {
+	case InitRandom:
+		return "random"
+	case InitKMeansPP:
+		return "k-means++"
+	case InitFarthestPoint:
+		return "farthest-point"
+	case InitGiven:
+		return "given"
+	default:
+		return fmt.Sprintf("KMeansInit(%d)", int(i))
+	}
+}
+
+// EmptyCluster is the way a cluster which lost all its points gets new ones
+type EmptyCluster int
+
+const (
+	// ReseedFarthest moves the point farthest from its centroid into the empty cluster
+	ReseedFarthest EmptyCluster = iota
+	// ReseedSplitLargest splits the largest cluster between its centroid and its farthest point
+	ReseedSplitLargest
+)
+
+func (e EmptyCluster) String() string {
+	switch e {
+	case ReseedFarthest:
+		return "farthest"
+	case ReseedSplitLargest:
+		return "split-largest"
+	default:
+		return fmt.Sprintf("EmptyCluster(%d)", int(e))
+	}
+}
+
+// KMeansOptions are the parameters of KMeans
+type KMeansOptions struct {
+	Init KMeansInit
+	// Centroids are the first k centroids with InitGiven
+	Centroids [][]float64
+	// Rand is the source of all the random choices, so a seeded one makes the clustering reproducible
+	// If it is nil a source seeded with the time is used
+	Rand *rand.Rand
+	// MaxIter is the largest number of iterations
+	MaxIter int
+	// NInit is the number of runs from different initialisations, of which the one with the lowest RSS is kept
+	NInit int
+	// EmptyCluster is the way the empty clusters are reseeded
+	EmptyCluster EmptyCluster
+	// Workers is the number of goroutines the points are shared between, GOMAXPROCS if it is not positive
+	// The result doesn't depend on it
+	Workers int
+	// Observer follows the iterations of KMeans and of em in GMMWithOptions, nothing is reported if it is nil
+	Observer TrainingObserver
+	// Weights are the positive weights of the points in the sums of the centroids, the variances and the RSS
+	// and in the M-step of em in GMMWithOptions, e.g. those of LoadWeightedFourierForFile
+	// All the points weigh 1 if it is nil
+	Weights []float64
+}
+
+// DefaultKMeansOptions returns the random initialisation KMeans has always used
+func DefaultKMeansOptions() KMeansOptions {
+	return KMeansOptions{
+		Init:         InitRandom,
+		MaxIter:      100,
+		NInit:        1,
+		EmptyCluster: ReseedFarthest,
+	}
+}
+
+// weight returns the weight of the i-th point
+func (o KMeansOptions) weight(i int) float64 {
+	if o.Weights == nil {
+		return 1
+	}
+	return o.Weights[i]
+}
+
+// checkWeights panics unless there is a positive weight for every one of the n points
+func (o KMeansOptions) checkWeights(n int) {
+	if o.Weights == nil {
+		return
+	}
+	if len(o.Weights) != n {
+		panic(fmt.Sprintf("%d weights for %d points", len(o.Weights), n))
+	}
+	for i, w := range o.Weights {
+		if !(w > 0) {
+			panic(fmt.Sprintf("the weight of point %d is %f", i, w))
+		}
+	}
+}
+
+func (o KMeansOptions) random() *rand.Rand {
+	if o.Rand == nil {
+		return rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
+	}
+	return o.Rand
+}
+
+// KMeansResult is the clustering of KMeansWithOptions
+type KMeansResult struct {
+	Clustered    []MfccClusterisable
+	Expectations [][]float64
+	Variances    [][]float64
+	Sizes        []int
+	// Iterations is the number of iterations of the kept run
+	Iterations int
+	// RSS is the sum of the squared distances of the points to their centroids in the kept run
+	RSS float64
+	// Reseeded is the number of empty clusters refilled in the kept run
+	Reseeded int
+}
+
 // KMeans takes all the mfccs for a file
 // which are of size nx39 (where n is file_len / 10ms)
 // and separates them into k clusters
 // then returns the means and variance of each cluster
 func KMeans(mfccsFloats [][]float64, k int) ([]MfccClusterisable, [][]float64, [][]float64, []int) {
-	e, variances := getμAndσ(mfccsFloats)
-	f, _ := os.Create("/tmp/danni")
-	defer f.Close()
+	result := KMeansWithOptions(mfccsFloats, k, DefaultKMeansOptions())
+	return result.Clustered, result.Expectations, result.Variances, result.Sizes
+}
 
-	fmt.Fprintf(f, "===EXP=====\n")
-	for i, ee := range e {
-		fmt.Fprintf(f, "i: %d e: %v\n", i, ee)
-	}
-	fmt.Fprintf(f, "===VAR=====\n")
-	for i, vv := range variances {
-		fmt.Fprintf(f, "i: %d v: %v\n", i, vv)
+// KMeansWithOptions is KMeans with the given initialisation and source of randomness
+// It runs options.NInit times and keeps the clustering with the lowest RSS
+func KMeansWithOptions(mfccsFloats [][]float64, k int, options KMeansOptions) KMeansResult {
+	return kMeansWithVariances(mfccsFloats, k, scalingVariances(mfccsFloats, options.Workers), options)
+}
+
+// kMeansWithVariances is KMeansWithOptions with the distances scaled by the given variances instead of those of the points
+func kMeansWithVariances(mfccsFloats [][]float64, k int, variances []float64, options KMeansOptions) KMeansResult {
+	options.checkWeights(len(mfccsFloats))
+
+	// all the runs share the source so they start differently
+	options.Rand = options.random()
+	runs := options.NInit
+	if runs < 1 || options.Init == InitGiven {
+		runs = 1
 	}
 
-	mfccs := make([]MfccClusterisable, len(mfccsFloats), len(mfccsFloats))
-	for i, mfcc := range mfccsFloats {
-		mfccs[i] = MfccClusterisable{
-			coefficients: mfcc,
-			clusterID:    -1,
+	var best KMeansResult
+	for run := 0; run < runs; run++ {
+		mfccs := make([]MfccClusterisable, len(mfccsFloats), len(mfccsFloats))
+		for i, mfcc := range mfccsFloats {
+			mfccs[i] = MfccClusterisable{
+				coefficients: mfcc,
+				clusterID:    -1,
+			}
 		}
-	}
 
-	fmt.Fprintf(os.Stderr, "\n================kMeans=================\n")
+		result := kMeans(mfccs, variances, k, options, run)
+		if run == 0 || result.RSS < best.RSS {
+			best = result
+		}
+	}
+	return best
+}
 
-	μ, σ, numInCluster := kMeans(mfccs, variances, k)
-	return mfccs, μ, σ, numInCluster
+// initialiseRandom returns the indices of k different random points
+func initialiseRandom(mfccs []MfccClusterisable, k int, random *rand.Rand) []int {
+	return random.Perm(len(mfccs))[:k]
 }
 
-func initialiseRandom(mfccs []MfccClusterisable, k int, variances []float64) map[int32]struct{} {
-	centroidIndices := make(map[int32]struct{})
+// initialiseKPP returns the indices of the points chosen by k-means++
+func initialiseKPP(mfccs []MfccClusterisable, k int, variances []float64, random *rand.Rand) []int {
+	chosen := []int{random.Intn(len(mfccs))}
+
+	// distances are the squared distances of every point to its closest centroid so far
+	distances := make([]float64, len(mfccs), len(mfccs))
+	for i := range distances {
+		distances[i] = math.Inf(1)
+	}
+
+	for len(chosen) < k {
+		last := mfccs[chosen[len(chosen)-1]].coefficients
+		var sum float64
+		for i, m := range mfccs {
+			distances[i] = math.Min(distances[i], mahalanobisDistance(m.coefficients, last, variances))
+			sum += distances[i]
+		}
+
+		// all the points are on the centroids, so any of the rest will do
+		if sum == 0 {
+			chosen = append(chosen, unchosen(len(mfccs), chosen, random))
+			continue
+		}
 
-	// First choose randomly the firts centroids
-	// Keep generating a new random number until there are k keys in centroidIndices
-	rand.Seed(time.Now().UTC().UnixNano())
-	for len(centroidIndices) < k {
-		ind := rand.Int31n(int32(len(mfccs)))
-		if _, ok := centroidIndices[ind]; !ok {
-			centroidIndices[ind] = struct{}{}
+		target := random.Float64() * sum
+		next := len(mfccs) - 1
+		for i, d := range distances {
+			target -= d
+			if target < 0 {
+				next = i
+				break
+			}
 		}
+		chosen = append(chosen, next)
 	}
 
-	return centroidIndices
+	return chosen
 }
 
-func initialiseKPP(mfccs []MfccClusterisable, k int, variances []float64) map[int32]struct{} {
-	centroidIndices := make(map[int32]struct{})
-	rand.Seed(time.Now().UTC().UnixNano())
-	centroidIndices[rand.Int31n(int32(len(mfccs)))] = struct{}{}
+// initialiseFarthest returns the indices of a random point and then always the one farthest from the chosen ones
+func initialiseFarthest(mfccs []MfccClusterisable, k int, variances []float64, random *rand.Rand) []int {
+	chosen := []int{random.Intn(len(mfccs))}
+
+	distances := make([]float64, len(mfccs), len(mfccs))
+	for i := range distances {
+		distances[i] = math.Inf(1)
+	}
 
-	for len(centroidIndices) < k {
-		max := math.Inf(-42)
+	for len(chosen) < k {
+		last := mfccs[chosen[len(chosen)-1]].coefficients
 		argmax := -1
-		for i, d := range mfccs {
-			curr := findClosestCentroidFromPoints(mfccs, centroidIndices, d.coefficients, variances)
-			if curr > max {
-				max = curr
+		for i, m := range mfccs {
+			distances[i] = math.Min(distances[i], mahalanobisDistance(m.coefficients, last, variances))
+			if distances[i] > 0 && (argmax == -1 || distances[i] > distances[argmax]) {
 				argmax = i
 			}
 		}
 
-		if _, ok := centroidIndices[int32(argmax)]; !ok {
-			centroidIndices[int32(argmax)] = struct{}{}
+		if argmax == -1 {
+			argmax = unchosen(len(mfccs), chosen, random)
 		}
+		chosen = append(chosen, argmax)
 	}
 
-	return centroidIndices
+	return chosen
 }
 
-func kMeans(mfccs []MfccClusterisable, variances []float64, k int) ([][]float64, [][]float64, []int) {
-	centroids := make([][]float64, 0, k)
-	for i := range initialiseRandom(mfccs, k, variances) {
-		centroids = append(centroids, mfccs[i].coefficients)
+// unchosen returns a random index which is not chosen yet
+func unchosen(n int, chosen []int, random *rand.Rand) int {
+	taken := make(map[int]struct{}, len(chosen))
+	for _, c := range chosen {
+		taken[c] = struct{}{}
 	}
 
-	iterations := 100
-	rsss := make([]float64, 0, iterations)
-	// Group the documents in clusters and recalculate the new centroid of the cluster
-	for times := 0; times < iterations; times++ {
-		for i := range mfccs {
-			mfccs[i].clusterID = findClosestCentroid(centroids, mfccs[i].coefficients, variances)
+	for _, i := range random.Perm(n) {
+		if _, ok := taken[i]; !ok {
+			return i
+		}
+	}
+	panic("all the points are chosen")
+}
+
+// initialCentroids returns copies of the first k centroids chosen according to the options
+func initialCentroids(mfccs []MfccClusterisable, variances []float64, k int, options KMeansOptions) [][]float64 {
+	if k > len(mfccs) {
+		panic(fmt.Sprintf("%d clusters for %d points", k, len(mfccs)))
+	}
+
+	var indices []int
+	switch options.Init {
+	case InitRandom:
+		indices = initialiseRandom(mfccs, k, options.random())
+	case InitKMeansPP:
+		indices = initialiseKPP(mfccs, k, variances, options.random())
+	case InitFarthestPoint:
+		indices = initialiseFarthest(mfccs, k, variances, options.random())
+	case InitGiven:
+		if len(options.Centroids) != k {
+			panic(fmt.Sprintf("%d given centroids for %d clusters", len(options.Centroids), k))
+		}
+		centroids := make([][]float64, k, k)
+		for i, c := range options.Centroids {
+			if len(c) != len(mfccs[0].coefficients) {
+				panic(fmt.Sprintf("centroid %d has %d coordinates instead of %d", i, len(c), len(mfccs[0].coefficients)))
+			}
+			centroids[i] = append([]float64{}, c...)
 		}
+		return centroids
+	default:
+		panic(fmt.Sprintf("unknown initialisation %s", options.Init))
+	}
+
+	centroids := make([][]float64, k, k)
+	for i, index := range indices {
+		centroids[i] = append([]float64{}, mfccs[index].coefficients...)
+	}
+	return centroids
+}
 
-		centroids = findNewCentroids(mfccs, k)
-		rsss = append(rsss, getRss(mfccs, centroids, variances))
+func kMeans(mfccs []MfccClusterisable, variances []float64, k int, options KMeansOptions, run int) KMeansResult {
+	observer := observerOrSilent(options.Observer)
+	centroids := initialCentroids(mfccs, variances, k, options)
+
+	iterations := options.MaxIter
+	reseeded := 0
+	rsss := make([]float64, 0, iterations)
+	// Group the documents in clusters and recalculate the new centroid of the cluster
+	times := 0
+	for ; times < iterations; times++ {
+		observer.IterationStart("kmeans", run, times)
+		assignClusters(mfccs, centroids, variances, options.Workers)
+		reseeded += fillEmptyClusters(mfccs, centroids, variances, k, options.EmptyCluster)
+
+		centroids = findNewCentroids(mfccs, k, options)
+		rsss = append(rsss, getRss(mfccs, centroids, variances, options))
+		observer.IterationEnd(TrainingIteration{
+			Algorithm: "kmeans",
+			Run:       run,
+			Iteration: times,
+			RSS:       rsss[times],
+			Centroids: centroids,
+		})
 
 		// break if there is no difference between new and old centroids
 		if times > 1 && math.Abs(rsss[times-1]-rsss[times]) < 0.0000001 {
-			fmt.Fprintf(os.Stderr, "Break on iteration %d RSS: %f\n", times, rsss[times])
+			times++
 			break
 		}
 	}
 
-	for i := range mfccs {
-		mfccs[i].clusterID = findClosestCentroid(centroids, mfccs[i].coefficients, variances)
+	assignClusters(mfccs, centroids, variances, options.Workers)
+	reseeded += fillEmptyClusters(mfccs, centroids, variances, k, options.EmptyCluster)
+
+	μ, σ, numInCluster := getClustersμσcount(mfccs, k, options)
+	return KMeansResult{
+		Clustered:    mfccs,
+		Expectations: μ,
+		Variances:    σ,
+		Sizes:        numInCluster,
+		Iterations:   times,
+		RSS:          getRss(mfccs, μ, variances, options),
+		Reseeded:     reseeded,
 	}
-
-	return getClustersμσcount(mfccs, k)
 }
 
-func getClustersμσcount(mfccs []MfccClusterisable, k int) ([][]float64, [][]float64, []int) {
-	expectations := make([][]float64, k, k)
-	expectationsSquared := make([][]float64, k, k)
-	variances := make([][]float64, k, k)
-	numInCluster := make([]int, k, k)
-
-	for i := 0; i < k; i++ {
-		expectations[i] = make([]float64, len(mfccs[0].coefficients))
-		expectationsSquared[i] = make([]float64, len(mfccs[0].coefficients))
-		variances[i] = make([]float64, len(mfccs[0].coefficients))
+// fillEmptyClusters moves points into the clusters which have none, where the points are assigned to the given centroids
+// and returns the number of refilled clusters
+func fillEmptyClusters(mfccs []MfccClusterisable, centroids [][]float64, variances []float64, k int, strategy EmptyCluster) int {
+	sizes := make([]int, k, k)
+	for _, mfcc := range mfccs {
+		sizes[mfcc.clusterID]++
 	}
 
-	for _, mfcc := range mfccs {
-		numInCluster[mfcc.clusterID]++
-		for i, c := range mfcc.coefficients {
-			expectations[mfcc.clusterID][i] += c
-			expectationsSquared[mfcc.clusterID][i] += c * c
+	refilled := 0
+	for empty := range sizes {
+		if sizes[empty] > 0 {
+			continue
 		}
-	}
+		refilled++
+
+		switch strategy {
+		case ReseedFarthest:
+			// the farthest point of a cluster which keeps at least one
+			farthest := -1
+			max := -1.0
+			for i, mfcc := range mfccs {
+				if sizes[mfcc.clusterID] < 2 {
+					continue
+				}
+				if d := mahalanobisDistance(mfcc.coefficients, centroids[mfcc.clusterID], variances); d > max {
+					max = d
+					farthest = i
+				}
+			}
+			sizes[mfccs[farthest].clusterID]--
+			mfccs[farthest].clusterID = int32(empty)
+			sizes[empty]++
+		case ReseedSplitLargest:
+			largest := 0
+			for c := range sizes {
+				if sizes[c] > sizes[largest] {
+					largest = c
+				}
+			}
 
-	for i := 0; i < k; i++ {
-		for j := 0; j < len(mfccs[0].coefficients); j++ {
-			expectations[i][j] /= float64(numInCluster[i])
-			expectationsSquared[i][j] /= float64(numInCluster[i])
-			variances[i][j] = expectationsSquared[i][j] - expectations[i][j]*expectations[i][j]
+			farthest := -1
+			max := -1.0
+			for i, mfcc := range mfccs {
+				if mfcc.clusterID != int32(largest) {
+					continue
+				}
+				if d := mahalanobisDistance(mfcc.coefficients, centroids[largest], variances); d > max {
+					max = d
+					farthest = i
+				}
+			}
+
+			// the points closer to the farthest one than to the centroid go to the empty cluster
+			seed := mfccs[farthest].coefficients
+			for i, mfcc := range mfccs {
+				if mfcc.clusterID != int32(largest) || sizes[largest] == 1 {
+					continue
+				}
+				if i == farthest || mahalanobisDistance(mfcc.coefficients, seed, variances) < mahalanobisDistance(mfcc.coefficients, centroids[largest], variances) {
+					mfccs[i].clusterID = int32(empty)
+					sizes[largest]--
+					sizes[empty]++
+				}
+			}
+		default:
+			panic(fmt.Sprintf("unknown empty cluster strategy %s", strategy))
 		}
 	}
+	return refilled
+}
 
-	fmt.Printf("numInCluster: %v\n", numInCluster)
-	for i := 0; i < len(numInCluster); i++ {
-		if numInCluster[i] == 1 {
-			fmt.Printf("%d\n", i)
+// getClustersμσcount returns the weighted means and variances and the number of points of the clusters
+func getClustersμσcount(mfccs []MfccClusterisable, k int, options KMeansOptions) ([][]float64, [][]float64, []int) {
+	stats := make([]*RunningStats, k, k)
+	for i := range stats {
+		stats[i] = NewRunningStats(len(mfccs[0].coefficients), false)
+	}
+	for i, mfcc := range mfccs {
+		stats[mfcc.clusterID].AddWeighted(mfcc.coefficients, options.weight(i))
+	}
+
+	expectations := make([][]float64, k, k)
+	variances := make([][]float64, k, k)
+	numInCluster := make([]int, k, k)
+	for i, s := range stats {
+		expectations[i] = s.Mean()
+		variances[i] = s.Variance()
+		numInCluster[i] = s.Count()
+		if numInCluster[i] == 0 {
+			continue
 		}
+		// a cluster of equal points would give em a singular gaussian
+		eps(&variances[i], EPS)
 	}
 
 	return expectations, variances, numInCluster
 }
 
 func Getσ(mfccs [][]float64) []float64 {
-	_, variances := getμAndσ(mfccs)
-	return variances
+	return scalingVariances(mfccs, 1)
 }
 
-func getμAndσ(mfccs [][]float64) ([]float64, []float64) {
-	fmt.Printf("Len mfccs: %d\n", len(mfccs))
-	variances := make([]float64, len(mfccs[0]), len(mfccs[0]))
+// scalingVariances returns the variances the distances between the frames are scaled with, at least EPS
+func scalingVariances(mfccs [][]float64, workers int) []float64 {
+	variances := statsOfShards(mfccs, workers).Variance()
+	eps(&variances, EPS)
+	return variances
+}
 
-	expectation := make([]float64, len(mfccs[0]), len(mfccs[0]))
-	expectationSquared := make([]float64, len(mfccs[0]), len(mfccs[0]))
-	for j := 0; j < len(mfccs[0]); j++ {
-		for i := 0; i < len(mfccs); i++ {
-			expectation[j] += mfccs[i][j]
-			expectationSquared[j] += expectation[j] * expectation[j]
+// assignClusters moves every point to the cluster of its closest centroid
+func assignClusters(mfccs []MfccClusterisable, centroids [][]float64, variances []float64, workers int) {
+	parallel(len(mfccs), workers, func(_ int, from int, to int) {
+		for i := from; i < to; i++ {
+			mfccs[i].clusterID = findClosestCentroid(centroids, mfccs[i].coefficients, variances)
 		}
-	}
+	})
+}
 
-	for j := 0; j < len(mfccs[0]); j++ {
-		expectation[j] /= float64(len(mfccs))
-		expectationSquared[j] /= float64(len(mfccs))
-		variances[j] = expectationSquared[j] - expectation[j]*expectation[j]
-		if variances[j] < EPS {
-			fmt.Fprintf(os.Stderr, fmt.Sprintf("variance is EPS: %d %f", j, variances[j]))
-			variances[j] = EPS
+func getRss(mfccs []MfccClusterisable, centroids [][]float64, variances []float64, options KMeansOptions) float64 {
+	partial := make([]float64, numShards(len(mfccs)), numShards(len(mfccs)))
+	parallel(len(mfccs), options.Workers, func(shard int, from int, to int) {
+		for i := from; i < to; i++ {
+			// the distance is already squared
+			partial[shard] += options.weight(i) * mahalanobisDistance(mfccs[i].coefficients, centroids[mfccs[i].clusterID], variances)
 		}
-	}
-
-	return expectation, variances
-}
+	})
 
-func getRss(mfccs []MfccClusterisable, centroids [][]float64, variances []float64) float64 {
 	var rss float64
-
-	for _, mfcc := range mfccs {
-		distance := mahalanobisDistance(mfcc.coefficients, centroids[mfcc.clusterID], variances)
-		rss += distance * distance
+	for _, p := range partial {
+		rss += p
 	}
-
 	return rss
 }
 
-func findNewCentroids(mfccs []MfccClusterisable, k int) [][]float64 {
-	centroids := make([][]float64, k, k)
+// findNewCentroids returns the weighted means of the clusters
+func findNewCentroids(mfccs []MfccClusterisable, k int, options KMeansOptions) [][]float64 {
+	// every shard sums its points into its own centroids, which are added up in order
+	shards := numShards(len(mfccs))
+	partialSums := make([][][]float64, shards, shards)
+	partialWeights := make([][]float64, shards, shards)
+	parallel(len(mfccs), options.Workers, func(shard int, from int, to int) {
+		partialSums[shard] = make([][]float64, k, k)
+		for i := range partialSums[shard] {
+			partialSums[shard][i] = make([]float64, len(mfccs[0].coefficients), len(mfccs[0].coefficients))
+		}
+		partialWeights[shard] = make([]float64, k, k)
+
+		for i := from; i < to; i++ {
+			w := options.weight(i)
+			partialWeights[shard][mfccs[i].clusterID] += w
+			if w == 1 {
+				add(&partialSums[shard][mfccs[i].clusterID], mfccs[i].coefficients)
+			} else {
+				add(&partialSums[shard][mfccs[i].clusterID], multiplied(mfccs[i].coefficients, w))
+			}
+		}
+	})
 
+	centroids := make([][]float64, k, k)
 	for i := range centroids {
 		centroids[i] = make([]float64, len(mfccs[0].coefficients), len(mfccs[0].coefficients))
 	}
-	mfccsInCluster := make([]int, k, k)
-
-	for _, mfcc := range mfccs {
-
-		mfccsInCluster[mfcc.clusterID]++
-		add(&centroids[mfcc.clusterID], mfcc.coefficients)
+	weightInCluster := make([]float64, k, k)
+	for shard := range partialSums {
+		for i := range centroids {
+			weightInCluster[i] += partialWeights[shard][i]
+			add(&centroids[i], partialSums[shard][i])
+		}
 	}
 
 	for i := range centroids {
-		divide(&centroids[i], float64(mfccsInCluster[i]))
+		if weightInCluster[i] > 0 {
+			divide(&centroids[i], weightInCluster[i])
+		}
 	}
 
 	return centroids
 }
 
-func findClosestCentroidFromPoints(mfccs []MfccClusterisable, centroidIds map[int32]struct{}, point []float64, variances []float64) float64 {
-	// Returns positive infty if argument is >=0
-	min := math.Inf(42)
-
-	for centroidID := range centroidIds {
-		currentDistance := mahalanobisDistance(mfccs[centroidID].coefficients, point, variances)
-		if currentDistance < min {
-			min = currentDistance
-		}
-	}
-	return min
-}
-
 func findClosestCentroid(centroids [][]float64, mfcc []float64, variances []float64) int32 {
 	// Returns positive infty if argument is >=0
 	min := math.Inf(42)
@@ -3,6 +3,8 @@ package emotions
 import (
 	"bufio"
 	"fmt"
+	"math"
+	"math/rand"
 	"os"
 	"strconv"
 	"strings"
@@ -59,3 +61,151 @@ func TestKMeans(t *testing.T) {
 	clustered, _, _, _ = KMeans(points2, k)
 	PlotClusters(clustered, k, "testing/kmeans_result.png")
 }
+
+func TestKMeansInitialisation(t *testing.T) {
+	points := [][]float64{
+		[]float64{0, 0}, []float64{0.1, 0}, []float64{0, 0.1},
+		[]float64{10, 10}, []float64{10.1, 10}, []float64{10, 10.1},
+		[]float64{0, 10}, []float64{0.1, 10}, []float64{0, 10.1},
+	}
+
+	for _, init := range []KMeansInit{InitRandom, InitKMeansPP, InitFarthestPoint} {
+		options := DefaultKMeansOptions()
+		options.Init = init
+
+		options.Rand = rand.New(rand.NewSource(7))
+		μ1 := KMeansWithOptions(points, 3, options).Expectations
+		options.Rand = rand.New(rand.NewSource(7))
+		μ2 := KMeansWithOptions(points, 3, options).Expectations
+		assert.Equal(t, μ1, μ2, init.String())
+	}
+
+	options := DefaultKMeansOptions()
+	options.Init = InitGiven
+	options.Centroids = [][]float64{[]float64{0, 10}, []float64{0, 0}, []float64{10, 10}}
+	result := KMeansWithOptions(points, 3, options)
+	clustered, count := result.Clustered, result.Sizes
+	assert.Equal(t, []int{3, 3, 3}, count)
+	assert.Equal(t, int32(1), clustered[0].clusterID)
+	assert.Equal(t, int32(2), clustered[3].clusterID)
+	assert.Equal(t, int32(0), clustered[6].clusterID)
+
+	// with three separated groups k-means++ never picks two centroids in the same one
+	random := rand.New(rand.NewSource(1))
+	mfccs := make([]MfccClusterisable, len(points), len(points))
+	for i, p := range points {
+		mfccs[i] = MfccClusterisable{coefficients: p}
+	}
+	for i := 0; i < 20; i++ {
+		groups := make(map[int]struct{})
+		for _, c := range initialiseKPP(mfccs, 3, []float64{1, 1}, random) {
+			groups[c/3] = struct{}{}
+		}
+		assert.Len(t, groups, 3)
+	}
+}
+
+func TestKMeansEmptyClusters(t *testing.T) {
+	points := [][]float64{
+		[]float64{0, 0}, []float64{0.1, 0}, []float64{0, 0.1},
+		[]float64{10, 10}, []float64{10.1, 10}, []float64{10, 10.1},
+	}
+
+	for _, strategy := range []EmptyCluster{ReseedFarthest, ReseedSplitLargest} {
+		options := DefaultKMeansOptions()
+		options.Init = InitGiven
+		options.EmptyCluster = strategy
+		// no point is closest to the third centroid
+		options.Centroids = [][]float64{[]float64{0, 0}, []float64{10, 10}, []float64{100, -100}}
+
+		result := KMeansWithOptions(points, 3, options)
+		assert.True(t, result.Reseeded > 0, strategy.String())
+		for c := range result.Sizes {
+			assert.True(t, result.Sizes[c] > 0, strategy.String())
+			for j := range result.Expectations[c] {
+				assert.False(t, math.IsNaN(result.Expectations[c][j]), strategy.String())
+				assert.True(t, result.Variances[c][j] >= EPS, strategy.String())
+			}
+		}
+	}
+}
+
+func TestKMeansRestarts(t *testing.T) {
+	points := make([][]float64, 0, 60)
+	random := rand.New(rand.NewSource(3))
+	for _, centre := range [][]float64{{0, 0}, {5, 0}, {0, 5}, {5, 5}} {
+		for i := 0; i < 15; i++ {
+			points = append(points, []float64{centre[0] + random.NormFloat64()*0.3, centre[1] + random.NormFloat64()*0.3})
+		}
+	}
+
+	options := DefaultKMeansOptions()
+	options.Rand = rand.New(rand.NewSource(11))
+	single := KMeansWithOptions(points, 4, options)
+
+	options.Rand = rand.New(rand.NewSource(11))
+	options.NInit = 10
+	restarted := KMeansWithOptions(points, 4, options)
+
+	assert.True(t, restarted.RSS <= single.RSS)
+	assert.True(t, restarted.Iterations > 0)
+	sum := 0
+	for _, size := range restarted.Sizes {
+		sum += size
+	}
+	assert.Equal(t, len(points), sum)
+}
+
+// syntheticClusters returns n points around k random centres in dim dimensions
+func syntheticClusters(random *rand.Rand, n int, k int, dim int) [][]float64 {
+	centres := make([][]float64, k, k)
+	for c := range centres {
+		centres[c] = make([]float64, dim, dim)
+		for j := range centres[c] {
+			centres[c][j] = random.Float64() * 20
+		}
+	}
+
+	points := make([][]float64, n, n)
+	for i := range points {
+		points[i] = make([]float64, dim, dim)
+		for j := range points[i] {
+			points[i][j] = centres[i%k][j] + random.NormFloat64()
+		}
+	}
+	return points
+}
+
+func TestKMeansWorkers(t *testing.T) {
+	points := syntheticClusters(rand.New(rand.NewSource(5)), 2000, 4, 3)
+
+	var results []KMeansResult
+	for _, workers := range []int{1, 3, 8} {
+		options := DefaultKMeansOptions()
+		options.Init = InitKMeansPP
+		options.Rand = rand.New(rand.NewSource(2))
+		options.Workers = workers
+		results = append(results, KMeansWithOptions(points, 4, options))
+	}
+
+	for _, r := range results[1:] {
+		assert.Equal(t, results[0].Expectations, r.Expectations)
+		assert.Equal(t, results[0].RSS, r.RSS)
+		assert.Equal(t, results[0].Sizes, r.Sizes)
+	}
+}
+
+func BenchmarkKMeans(b *testing.B) {
+	points := syntheticClusters(rand.New(rand.NewSource(1)), 20000, 8, 39)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				options := DefaultKMeansOptions()
+				options.Rand = rand.New(rand.NewSource(1))
+				options.MaxIter = 10
+				options.Workers = workers
+				KMeansWithOptions(points, 8, options)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math"
+	"os"
 	"sort"
 )
 
@@ -32,60 +32,21 @@ func UnmarshallGMMEeg(filename string) ([]EmotionGausianMixure, error) {
 	return nil, nil
 }
 
+// GetμAndσTagged returns the mean and the variance (at least EPS) of every coordinate of all the tagged vectors
 func GetμAndσTagged(tagged []Tagged) ([]float64, []float64) {
-	featureVectorSize := len(tagged[0].Data[0])
-	// fmt.Fprintf(os.Stderr, "FeatureVectorSize: %d\n", featureVectorSize)
-
-	variances := make([]float64, featureVectorSize, featureVectorSize)
-
-	expectation := make([]float64, featureVectorSize, featureVectorSize)
-	expectationSquared := make([]float64, featureVectorSize, featureVectorSize)
-
-	for t := range tagged {
-		for i := range tagged[t].Data {
-			for j := range tagged[t].Data[i] {
-				expectation[j] += tagged[t].Data[i][j]
-				expectationSquared[j] += expectation[j] * expectation[j]
-			}
-		}
-	}
-
-	numVectors := 0
+	stats := NewRunningStats(len(tagged[0].Data[0]), false)
 	for t := range tagged {
-		numVectors += len(tagged[t].Data)
-	}
-
-	// fmt.Fprintf(os.Stderr, "numVectors: %d\n", numVectors)
-	for j := 0; j < featureVectorSize; j++ {
-		expectation[j] /= float64(numVectors)
-		expectationSquared[j] /= float64(numVectors)
-
-		variances[j] = expectationSquared[j] - expectation[j]*expectation[j]
-	}
-
-	// fmt.Fprintf(os.Stderr, "Expectations: %d\n", len(expectation))
-	// fmt.Fprintf(os.Stderr, "Variances: %d\n", len(variances))
-	return expectation, variances
-}
-
-func findClosest(v []float64, trainSet []Tagged, trainVar []float64) string {
-	minDist := math.Inf(42)
-	minDistTag := ""
-
-	for t := range trainSet {
-		for tv := range trainSet[t].Data {
-			curDist := mahalanobisDistance(v, trainSet[t].Data[tv], trainVar)
-			if curDist < minDist {
-				minDist = curDist
-				minDistTag = trainSet[t].Tag
-			}
+		for _, v := range tagged[t].Data {
+			stats.Add(v)
 		}
 	}
 
-	return minDistTag
+	variances := stats.Variance()
+	eps(&variances, EPS)
+	return stats.Mean(), variances
 }
 
-func testKNN(emotion string, emotions []string, vectors [][]float64, trainSet []Tagged, trainVar []float64) (int, int, int) {
+func testKNN(emotion string, emotions []string, vectors [][]float64, index *NeighbourIndex) (int, int, int) {
 	fmt.Printf("%s\t", emotion)
 
 	counters := make(map[string]int)
@@ -94,7 +55,7 @@ func testKNN(emotion string, emotions []string, vectors [][]float64, trainSet []
 	}
 
 	for v := range vectors {
-		counters[findClosest(vectors[v], trainSet, trainVar)]++
+		counters[index.Nearest(vectors[v], 1)[0].Tag]++
 	}
 
 	sum := 0
@@ -107,13 +68,43 @@ func testKNN(emotion string, emotions []string, vectors [][]float64, trainSet []
 	return correct(emotion, counters), counters[emotion], sum
 }
 
-func ClassifyKNN(featureType string, trainSetFilename string, bucketSize int, frameLen int, frameStep int, emotionFiles map[string][]string) error {
-	trainSet, err := UnmarshallKNNEeg(trainSetFilename)
+// BuildKNNIndex builds the index of the training set saved by SaveEegTrainingSet and saves it for ClassifyKNNWithIndex
+func BuildKNNIndex(trainSetFilename string, indexFilename string) error {
+	index, err := knnIndex(trainSetFilename)
 	if err != nil {
 		return err
 	}
+	return index.Save(indexFilename)
+}
+
+func knnIndex(trainSetFilename string) (*NeighbourIndex, error) {
+	trainSet, err := UnmarshallKNNEeg(trainSetFilename)
+	if err != nil {
+		return nil, err
+	}
+	if len(trainSet) == 0 || len(trainSet[0].Data) == 0 {
+		return nil, fmt.Errorf("%s: empty training set", trainSetFilename)
+	}
 	_, trainVar := GetμAndσTagged(trainSet)
 
+	index, err := NewNeighbourIndex(trainSet, trainVar)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", trainSetFilename, err)
+	}
+	return index, nil
+}
+
+func ClassifyKNN(featureType string, trainSetFilename string, bucketSize int, frameLen int, frameStep int, emotionFiles map[string][]string) error {
+	index, err := knnIndex(trainSetFilename)
+	if err != nil {
+		return err
+	}
+	return ClassifyKNNWithIndex(featureType, index, bucketSize, frameLen, frameStep, emotionFiles)
+}
+
+// ClassifyKNNWithIndex is ClassifyKNN with a prebuilt index of the training set (see BuildKNNIndex and LoadNeighbourIndex)
+func ClassifyKNNWithIndex(featureType string, index *NeighbourIndex, bucketSize int, frameLen int, frameStep int, emotionFiles map[string][]string) error {
+
 	fileKeys := make([]string, 0, len(emotionFiles))
 	for k := range emotionFiles {
 		fileKeys = append(fileKeys, k)
@@ -123,15 +114,22 @@ func ClassifyKNN(featureType string, trainSetFilename string, bucketSize int, fr
 	correctFiles := make(map[string]int, len(fileKeys))
 	correctVectors := make(map[string]int, len(fileKeys))
 	sumVectors := make(map[string]int, len(fileKeys))
+	// the files which could not be read are skipped and not counted
+	numFiles := make(map[string]int, len(fileKeys))
 
 	for _, emotion := range fileKeys {
 		for _, f := range emotionFiles[emotion] {
+			vec, err := LoadFourierForFile(f, AllElectrodes, frameLen, frameStep)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skipping %s\n", err)
+				continue
+			}
+			numFiles[emotion]++
 			fmt.Printf("%s\t", emotion)
-			vec := GetFourierForFile(f, 19, frameLen, frameStep)
 			average := GetAverage(bucketSize, frameStep, len(vec))
 			averaged := AverageSlice(vec, average)
 
-			boolCorrect, correctVector, sumVector := testKNN(emotion, fileKeys, averaged, trainSet, trainVar)
+			boolCorrect, correctVector, sumVector := testKNN(emotion, fileKeys, averaged, index)
 			correctFiles[emotion] += boolCorrect
 			correctVectors[emotion] += correctVector
 			sumVectors[emotion] += sumVector
@@ -140,7 +138,7 @@ func ClassifyKNN(featureType string, trainSetFilename string, bucketSize int, fr
 	sort.Strings(fileKeys)
 	fmt.Printf("\tCorrectFiles\tCorrectVectors\n")
 	for _, emotion := range fileKeys {
-		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(len(emotionFiles[emotion])), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
+		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(numFiles[emotion]), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
 	}
 
 	return nil
@@ -160,19 +158,25 @@ func ClassifyGMM(featureType string, trainSetFilename string, bucketSize int, fr
 	correctFiles := make(map[string]int, len(fileKeys))
 	correctVectors := make(map[string]int, len(fileKeys))
 	sumVectors := make(map[string]int, len(fileKeys))
+	// the files which could not be read are skipped and not counted
+	numFiles := make(map[string]int, len(fileKeys))
 
 	sort.Strings(fileKeys)
 	for _, emotion := range fileKeys {
 		for _, f := range emotionFiles[emotion] {
-			vec := GetFourierForFile(f, 19, frameLen, frameStep)
-			average := GetAverage(bucketSize, frameStep, len(vec))
-
-			var averaged [][]float64
+			var vec [][]float64
 			if featureType == "de" {
-				averaged = GetDE(AverageSlice(vec, average))
+				vec, err = LoadAsymmetryForFile(f, AllElectrodes, frameLen, frameStep)
 			} else {
-				averaged = AverageSlice(vec, average)
+				vec, err = LoadFourierForFile(f, AllElectrodes, frameLen, frameStep)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skipping %s\n", err)
+				continue
 			}
+			numFiles[emotion]++
+			average := GetAverage(bucketSize, frameStep, len(vec))
+			averaged := AverageSlice(vec, average)
 
 			boolCorrect, vectors, sumVector := TestGMM(emotion, fileKeys, averaged, trainSet, true)
 			correctFiles[emotion] += boolCorrect
@@ -182,7 +186,7 @@ func ClassifyGMM(featureType string, trainSetFilename string, bucketSize int, fr
 	}
 	fmt.Printf("\tCorrectFiles\tCorrectVectors\n")
 	for _, emotion := range fileKeys {
-		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(len(emotionFiles[emotion])), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
+		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(numFiles[emotion]), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
 	}
 
 	return nil
@@ -202,11 +206,18 @@ func ClassifyGMMConcat(trainSetFilename string, speechFiles map[string][]string,
 	correctFiles := make(map[string]int, len(fileKeys))
 	correctVectors := make(map[string]int, len(fileKeys))
 	sumVectors := make(map[string]int, len(fileKeys))
+	// the files whose eeg could not be read are skipped and not counted
+	numFiles := make(map[string]int, len(fileKeys))
 
 	sort.Strings(fileKeys)
 	for _, emotion := range fileKeys {
 		for i := 0; i < len(speechFiles[emotion]); i++ {
-			eegFeatures := GetFourierForFile(eegFiles[emotion][i], 19, 200, 150)
+			eegFeatures, err := LoadFourierForFile(eegFiles[emotion][i], AllElectrodes, 200, 150)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skipping %s\n", err)
+				continue
+			}
+			numFiles[emotion]++
 			allSpeech := ReadSpeechFeaturesOne(speechFiles[emotion][i])
 			averaged := AverageSlice(allSpeech, len(allSpeech)/len(eegFeatures))
 			speechFeatures := averaged[0 : len(averaged)-(len(averaged)-len(eegFeatures))]
@@ -219,24 +230,36 @@ func ClassifyGMMConcat(trainSetFilename string, speechFiles map[string][]string,
 	}
 	fmt.Printf("\tCorrectFiles\tCorrectVectors\n")
 	for _, emotion := range fileKeys {
-		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(len(speechFiles[emotion])), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
+		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(numFiles[emotion]), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
 	}
 
 	return nil
 }
 
-func GetEegFeaturesForFile(bucketSize int, file string) [][]float64 {
+// LoadEegFeaturesForFile returns the fourier features of the eeg file (see LoadFourierForFile) averaged in buckets
+func LoadEegFeaturesForFile(bucketSize int, file string) ([][]float64, error) {
 	frameLen := 200
 	frameStep := 150
 
-	data := GetFourierForFile(file, 19, frameLen, frameStep)
+	data, err := LoadFourierForFile(file, AllElectrodes, frameLen, frameStep)
+	if err != nil {
+		return nil, err
+	}
 	average := GetAverage(bucketSize, frameLen, len(data))
-	return AverageSlice(data, average)
+	return AverageSlice(data, average), nil
+}
+
+// GetEegFeaturesForFile is LoadEegFeaturesForFile which panics on invalid files
+func GetEegFeaturesForFile(bucketSize int, file string) [][]float64 {
+	features, err := LoadEegFeaturesForFile(bucketSize, file)
+	if err != nil {
+		panic(err)
+	}
+	return features
 }
 
 func GetSpeechFeatureForFile(filename string) [][]float64 {
-	wf, _ := Read(filename, 0.01, 0.97)
-	return MFCCs(wf, 13, 23)
+	return speechFeatures(filename)
 }
 
 func ClassifyGMMBoth(bucketSize int, frameLen int, frameStep int, speechTrainDir string, speechFiles map[string][]string, eegTrainDir string, eegFiles map[string][]string) error {
@@ -276,9 +299,17 @@ func ClassifyGMMBoth(bucketSize int, frameLen int, frameStep int, speechTrainDir
 		bothAccuracy[emotion] = 0
 	}
 
+	// the files whose eeg could not be read are skipped and not counted
+	numFiles := make(map[string]int, len(fileKeys))
 	for _, emotion := range fileKeys {
 		for i := 0; i < len(speechFiles[emotion]); i++ {
-			sC, eC, bC, bA := TestGMMBoth(emotion, fileKeys, speechAlphaTrainSet, speechTrainSet, speechFiles[emotion][i], eegAlphaTrainSet, eegTrainSet, eegFiles[emotion][i], bucketSize)
+			eegFeatures, err := LoadEegFeaturesForFile(bucketSize, eegFiles[emotion][i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skipping %s\n", err)
+				continue
+			}
+			numFiles[emotion]++
+			sC, eC, bC, bA := testGMMBoth(emotion, fileKeys, speechAlphaTrainSet, speechTrainSet, GetSpeechFeatureForFile(speechFiles[emotion][i]), eegAlphaTrainSet, eegTrainSet, eegFeatures)
 
 			speechAccuracy[emotion] += sC
 			EEGAccuracy[emotion] += eC
@@ -296,7 +327,7 @@ func ClassifyGMMBoth(bucketSize int, frameLen int, frameStep int, speechTrainDir
 	}
 	fmt.Printf("Accuracy\n")
 	for _, emotion := range fileKeys {
-		fmt.Printf("%s\t%f\t%f\t%f\n", emotion, float64(speechAccuracy[emotion])/float64(len(speechFiles[emotion])), float64(EEGAccuracy[emotion])/float64(len(speechFiles[emotion])), float64(bothAccuracy[emotion])/float64(len(speechFiles[emotion])))
+		fmt.Printf("%s\t%f\t%f\t%f\n", emotion, float64(speechAccuracy[emotion])/float64(numFiles[emotion]), float64(EEGAccuracy[emotion])/float64(numFiles[emotion]), float64(bothAccuracy[emotion])/float64(numFiles[emotion]))
 	}
 
 	return nil
@@ -329,9 +360,16 @@ func ClassifyGMMBothConcat(speechTrainDir string, speechFiles map[string][]strin
 		sumVectors[emotion] = 0
 	}
 
+	// the files whose eeg could not be read are skipped and not counted
+	numFiles := make(map[string]int, len(fileKeys))
 	for _, emotion := range fileKeys {
 		for i := 0; i < len(speechFiles[emotion]); i++ {
-			eegFeatures := GetEegFeaturesForFile(0, eegFiles[emotion][i])
+			eegFeatures, err := LoadEegFeaturesForFile(0, eegFiles[emotion][i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skipping %s\n", err)
+				continue
+			}
+			numFiles[emotion]++
 			allFeatures := GetSpeechFeatureForFile(speechFiles[emotion][i])
 			averaged := AverageSlice(allFeatures, len(allFeatures)/len(eegFeatures))
 			speechFeatures := averaged[0 : len(averaged)-(len(averaged)-len(eegFeatures))]
@@ -345,7 +383,7 @@ func ClassifyGMMBothConcat(speechTrainDir string, speechFiles map[string][]strin
 	}
 	fmt.Printf("\tCorrectFiles\tCorrectVectors\n")
 	for _, emotion := range fileKeys {
-		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(len(speechFiles[emotion])), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
+		fmt.Printf("%s\t%f\t%f\n", emotion, float64(correctFiles[emotion])/float64(numFiles[emotion]), float64(correctVectors[emotion])/float64(sumVectors[emotion]))
 	}
 
 	return nil
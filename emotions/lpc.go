@@ -0,0 +1,140 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+)
+
+// autocorrelation returns the first p+1 autocorrelation coefficients r[0..p] of the frame
+func autocorrelation(frame []float64, p int) []float64 {
+	r := make([]float64, p+1, p+1)
+	for k := 0; k <= p; k++ {
+		for n := k; n < len(frame); n++ {
+			r[k] += frame[n] * frame[n-k]
+		}
+	}
+	return r
+}
+
+// LevinsonDurbin solves the normal equations for the autocorrelation r[0..p]
+// It returns the polynomial A(z) = 1 + a[1]z^-1 + ... + a[p]z^-p (a[0] is always 1),
+// the p reflection coefficients and the final prediction error
+// If the frame is silent (r[0] == 0) A(z) = 1 and the error is 0
+func LevinsonDurbin(r []float64, p int) ([]float64, []float64, float64) {
+	a := make([]float64, p+1, p+1)
+	reflection := make([]float64, p, p)
+	a[0] = 1.0
+
+	predictionError := r[0]
+	if predictionError <= 0 {
+		return a, reflection, 0
+	}
+
+	previous := make([]float64, p+1, p+1)
+	for i := 1; i <= p; i++ {
+		acc := r[i]
+		for j := 1; j < i; j++ {
+			acc += a[j] * r[i-j]
+		}
+
+		k := -acc / predictionError
+		reflection[i-1] = k
+
+		copy(previous, a)
+		for j := 1; j < i; j++ {
+			a[j] = previous[j] + k*previous[i-j]
+		}
+		a[i] = k
+
+		predictionError *= 1 - k*k
+		if predictionError <= 0 {
+			break
+		}
+	}
+
+	return a, reflection, predictionError
+}
+
+// LPC returns the order p linear prediction polynomial (with a[0] = 1), the reflection coefficients and the prediction error of a frame
+func LPC(frame []float64, p int) ([]float64, []float64, float64) {
+	return LevinsonDurbin(autocorrelation(frame, p), p)
+}
+
+// lpcToCepstrum converts the all-pole model gain/A(z) into C cepstral coefficients
+// c[0] is log(gain), the rest follow the usual recursion
+// c[n] = -a[n] - Σ_k=1^n-1 (k/n) c[k] a[n-k]
+func lpcToCepstrum(a []float64, gain float64, C int) []float64 {
+	p := len(a) - 1
+	c := make([]float64, C, C)
+	if C == 0 {
+		return c
+	}
+
+	c[0] = safeLog(gain)
+	for n := 1; n < C; n++ {
+		if n <= p {
+			c[n] = -a[n]
+		}
+		for k := Max(1, n-p); k < n; k++ {
+			c[n] -= float64(k) / float64(n) * c[k] * a[n-k]
+		}
+	}
+
+	return c
+}
+
+// checkLPCOrder panics unless the order of the prediction p and the number of cepstra C are positive
+func checkLPCOrder(p int, C int) {
+	if p < 1 {
+		panic(fmt.Sprintf("the lpc order %d is not positive", p))
+	}
+	if C < 1 {
+		panic(fmt.Sprintf("the number of cepstral coefficients %d is not positive", C))
+	}
+}
+
+// LPCs returns the p lpc coefficients a[1..p] for every frame of the wav file
+func LPCs(wf WavFile, p int) [][]float64 {
+	checkLPCOrder(p, 1)
+	frames := CutWavFileIntoFrames(wf)
+	lpcs := make([][]float64, len(frames), len(frames))
+
+	for i, frame := range frames {
+		a, _, _ := LPC(frame, p)
+		lpcs[i] = a[1:]
+	}
+
+	return lpcs
+}
+
+// ReflectionCoefficients returns the p reflection (PARCOR) coefficients for every frame of the wav file
+func ReflectionCoefficients(wf WavFile, p int) [][]float64 {
+	checkLPCOrder(p, 1)
+	frames := CutWavFileIntoFrames(wf)
+	reflections := make([][]float64, len(frames), len(frames))
+
+	for i, frame := range frames {
+		_, reflections[i], _ = LPC(frame, p)
+	}
+
+	return reflections
+}
+
+// LPCCs returns the lpc-cepstra of order p, their first and second derivatives
+// like MFCCs the first of the C coefficients is replaced with the log energy of the frame
+// It panics unless p and C are positive
+func LPCCs(wf WavFile, p int, C int) [][]float64 {
+	checkLPCOrder(p, C)
+	frames := CutWavFileIntoFrames(wf)
+	lpccs := make([][]float64, len(frames), len(frames))
+
+	for i, frame := range frames {
+		r := autocorrelation(frame, p)
+		a, _, predictionError := LevinsonDurbin(r, p)
+
+		lpccs[i] = lpcToCepstrum(a, math.Sqrt(predictionError), C)
+		lpccs[i][0] = safeLog(r[0])
+	}
+
+	return MFCCcDouble(lpccs)
+}
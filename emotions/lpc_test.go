@@ -0,0 +1,117 @@
+package emotions
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevinsonDurbin(t *testing.T) {
+	// autocorrelation of an AR(1) process x[n] = 0.5x[n-1] + e[n]
+	r := []float64{1.0, 0.5, 0.25}
+	a, reflection, predictionError := LevinsonDurbin(r, 2)
+
+	assert.InDelta(t, 1.0, a[0], EPS)
+	assert.InDelta(t, -0.5, a[1], EPS)
+	assert.InDelta(t, 0.0, a[2], EPS)
+	assert.InDelta(t, -0.5, reflection[0], EPS)
+	assert.InDelta(t, 0.0, reflection[1], EPS)
+	assert.InDelta(t, 0.75, predictionError, EPS)
+
+	// the cepstrum of 1/(1 - 0.5z^-1) is 0.5^n/n
+	c := lpcToCepstrum(a, 1.0, 5)
+	for n := 1; n < 5; n++ {
+		assert.InDelta(t, math.Pow(0.5, float64(n))/float64(n), c[n], EPS)
+	}
+}
+
+func TestPolynomialRoots(t *testing.T) {
+	// (z - 1)(z - 2)(z^2 + 1)
+	roots := polynomialRoots([]float64{1, -3, 3, -3, 2})
+
+	re := make([]float64, 0, 2)
+	im := make([]float64, 0, 2)
+	for _, r := range roots {
+		if math.Abs(r.Im) < EPS {
+			re = append(re, r.Re)
+		} else {
+			im = append(im, r.Im)
+			assert.InDelta(t, 0.0, r.Re, EPS)
+		}
+	}
+	sort.Float64s(re)
+	sort.Float64s(im)
+
+	assert.Equal(t, 2, len(re))
+	assert.InDelta(t, 1.0, re[0], EPS)
+	assert.InDelta(t, 2.0, re[1], EPS)
+	assert.Equal(t, 2, len(im))
+	assert.InDelta(t, -1.0, im[0], EPS)
+	assert.InDelta(t, 1.0, im[1], EPS)
+}
+
+// syntheticVowel returns a second of a 100Hz impulse train through two-pole resonators at the formants
+func syntheticVowel(sampleRate int, formants []float64, bandwidth float64) WavFile {
+	data := make([]float64, sampleRate, sampleRate)
+	for i := 0; i < len(data); i += sampleRate / 100 {
+		data[i] = 1
+	}
+
+	for _, f := range formants {
+		r := math.Exp(-math.Pi * bandwidth / float64(sampleRate))
+		θ := 2 * math.Pi * f / float64(sampleRate)
+		filtered := make([]float64, len(data), len(data))
+		for i := range data {
+			filtered[i] = data[i]
+			if i >= 1 {
+				filtered[i] += 2 * r * math.Cos(θ) * filtered[i-1]
+			}
+			if i >= 2 {
+				filtered[i] -= r * r * filtered[i-2]
+			}
+		}
+		data = filtered
+	}
+
+	return WavFile{sampleRate: uint32(sampleRate), data: data}
+}
+
+func TestFormants(t *testing.T) {
+	wf := syntheticVowel(8000, []float64{700, 1220, 2600}, 80)
+	formants := Formants(wf, 10, 3)
+	assert.Equal(t, len(CutWavFileIntoFrames(wf)), len(formants))
+
+	for _, f := range formants {
+		assert.InDelta(t, 700, f[0], 30)
+		assert.InDelta(t, 1220, f[1], 30)
+		assert.InDelta(t, 2600, f[2], 30)
+		for _, bandwidth := range f[3:] {
+			assert.True(t, bandwidth > 0 && bandwidth < maxFormantBandwidth)
+		}
+	}
+
+	assert.Panics(t, func() { Formants(wf, 4, 3) })
+}
+
+func TestLPCCsAndPLPs(t *testing.T) {
+	wf := syntheticVowel(16000, []float64{500, 1500, 2500}, 100)
+	mfccs := MFCCs(wf, 13, 23)
+
+	// the same layout as the mfccs: the coefficients with the log energy first, their first and second derivatives
+	for _, features := range [][][]float64{LPCCs(wf, 16, 13), PLPs(wf, 12, 13, 21, false), PLPs(wf, 12, 13, 21, true)} {
+		assert.Equal(t, len(mfccs), len(features))
+		for i, f := range features {
+			assert.Equal(t, len(mfccs[i]), len(f))
+			assert.InDelta(t, mfccs[i][0], f[0], 1e-9)
+			for _, v := range f {
+				assert.False(t, math.IsNaN(v) || math.IsInf(v, 0))
+			}
+		}
+	}
+
+	assert.Panics(t, func() { LPCCs(wf, 16, 0) })
+	assert.Panics(t, func() { PLPs(wf, 12, 0, 21, false) })
+	assert.Panics(t, func() { PLPs(wf, 12, 13, 2, false) })
+}
@@ -43,7 +43,7 @@ func triangleBank(coefficients []Complex, s, e, center float64) float64 {
 		}
 	}
 
-	return math.Log(sum)
+	return safeLog(sum)
 }
 
 // bank takes the fourier coefficient for one frame
@@ -72,7 +72,7 @@ func MFCCs(wf WavFile, C int, M int) [][]float64 {
 	for i, frame := range frames {
 		melScaleFrames[i] = make([]float64, M, M)
 		frameCoefficients, energy := FftReal(frame)
-		energies[i] = math.Log(energy)
+		energies[i] = safeLog(energy)
 		melScaleFrames[i] = melScale(frameCoefficients, int(wf.sampleRate), M)
 	}
 
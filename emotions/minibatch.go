@@ -0,0 +1,316 @@
+package emotions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+)
+
+// FrameIterator yields feature frames one at a time and io.EOF after the last one (see EEGXMLReader)
+type FrameIterator interface {
+	Next() ([]float64, error)
+}
+
+type sliceFrames struct {
+	frames [][]float64
+	next   int
+}
+
+func (s *sliceFrames) Next() ([]float64, error) {
+	if s.next == len(s.frames) {
+		return nil, io.EOF
+	}
+	s.next++
+	return s.frames[s.next-1], nil
+}
+
+// SliceFrames iterates over the frames in memory
+func SliceFrames(frames [][]float64) FrameIterator {
+	return &sliceFrames{frames: frames}
+}
+
+type channelFrames <-chan []float64
+
+func (c channelFrames) Next() ([]float64, error) {
+	frame, ok := <-c
+	if !ok {
+		return nil, io.EOF
+	}
+	return frame, nil
+}
+
+// ChannelFrames iterates over the frames sent on the channel until it is closed
+func ChannelFrames(frames <-chan []float64) FrameIterator {
+	return channelFrames(frames)
+}
+
+type speechFrames struct {
+	filenames []string
+	current   [][]float64
+	next      int
+}
+
+func (s *speechFrames) Next() ([]float64, error) {
+	for s.next == len(s.current) {
+		if len(s.filenames) == 0 {
+			return nil, io.EOF
+		}
+		s.current = speechFeatures(s.filenames[0])
+		s.filenames = s.filenames[1:]
+		s.next = 0
+	}
+	s.next++
+	return s.current[s.next-1], nil
+}
+
+// SpeechFrames iterates over the mfccs of the files (see ReadSpeechFeatures) holding a single file in memory
+func SpeechFrames(filenames []string) FrameIterator {
+	return &speechFrames{filenames: filenames}
+}
+
+// MiniBatchOptions are the parameters of MiniBatchKMeans
+type MiniBatchOptions struct {
+	// BatchSize is the number of frames of every update
+	BatchSize int
+	// Init chooses the first centroids from the first batch, see KMeansOptions
+	Init      KMeansInit
+	Centroids [][]float64
+	Rand      *rand.Rand
+	// Checkpoint is the file the state is saved to every CheckpointEvery batches and at the end of Fit
+	// No checkpoints are saved if it is empty
+	Checkpoint      string
+	CheckpointEvery int
+}
+
+// DefaultMiniBatchOptions returns batches of 1024 frames with k-means++ initialisation and no checkpoints
+func DefaultMiniBatchOptions() MiniBatchOptions {
+	return MiniBatchOptions{
+		BatchSize:       1024,
+		Init:            InitKMeansPP,
+		CheckpointEvery: 100,
+	}
+}
+
+// MiniBatchKMeans clusters a stream of frames a batch at a time (Sculley, Web-scale k-means clustering)
+// Every centroid moves towards the frames assigned to it with a learning rate of 1 / the number of frames it has seen
+type MiniBatchKMeans struct {
+	K         int
+	Centroids [][]float64
+	// Counts is the number of frames every centroid has seen
+	Counts []int
+	// Variances scale the distances like in KMeans, they are estimated from the first batch
+	Variances []float64
+	// Frames is the number of frames seen so far, which a Fit resumed from a checkpoint skips
+	Frames  int
+	Batches int
+
+	options MiniBatchOptions
+}
+
+// NewMiniBatchKMeans returns k clusters which are initialised with the first batch
+func NewMiniBatchKMeans(k int, options MiniBatchOptions) *MiniBatchKMeans {
+	return &MiniBatchKMeans{K: k, options: options}
+}
+
+// LoadMiniBatchKMeans resumes from a checkpoint with the given options
+func LoadMiniBatchKMeans(filename string, options MiniBatchOptions) (*MiniBatchKMeans, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MiniBatchKMeans{options: options}
+	if err := json.Unmarshal(bytes, m); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if len(m.Centroids) != m.K || len(m.Counts) != m.K {
+		return nil, fmt.Errorf("%s: %d centroids and %d counts for %d clusters", filename, len(m.Centroids), len(m.Counts), m.K)
+	}
+	return m, nil
+}
+
+// Save writes the state to the file, replacing it only after it is fully written
+func (m *MiniBatchKMeans) Save(filename string) error {
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return replaceFile(filename, bytes)
+}
+
+func (m *MiniBatchKMeans) initialise(batch [][]float64) error {
+	if len(batch) < m.K {
+		return fmt.Errorf("the first batch has %d frames for %d clusters", len(batch), m.K)
+	}
+
+	m.Variances = scalingVariances(batch, 1)
+	mfccs := make([]MfccClusterisable, len(batch), len(batch))
+	for i, frame := range batch {
+		mfccs[i] = MfccClusterisable{coefficients: frame, clusterID: -1}
+	}
+
+	m.Centroids = initialCentroids(mfccs, m.Variances, m.K, KMeansOptions{
+		Init:      m.options.Init,
+		Centroids: m.options.Centroids,
+		Rand:      m.options.Rand,
+	})
+	m.Counts = make([]int, m.K, m.K)
+	return nil
+}
+
+// Update moves the centroids towards the frames of the batch
+func (m *MiniBatchKMeans) Update(batch [][]float64) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if m.Centroids == nil {
+		if err := m.initialise(batch); err != nil {
+			return err
+		}
+	}
+	for i, frame := range batch {
+		if len(frame) != len(m.Variances) {
+			return fmt.Errorf("frame %d has %d coordinates instead of %d", m.Frames+i, len(frame), len(m.Variances))
+		}
+	}
+
+	// the whole batch is assigned before any centroid moves
+	assigned := make([]int32, len(batch), len(batch))
+	for i, frame := range batch {
+		assigned[i] = findClosestCentroid(m.Centroids, frame, m.Variances)
+	}
+
+	for i, frame := range batch {
+		c := assigned[i]
+		m.Counts[c]++
+		η := 1 / float64(m.Counts[c])
+		for j := range m.Centroids[c] {
+			m.Centroids[c][j] = (1-η)*m.Centroids[c][j] + η*frame[j]
+		}
+	}
+
+	m.Frames += len(batch)
+	m.Batches++
+	return nil
+}
+
+// Fit updates the centroids with every batch of the frames, saving checkpoints on the way
+// The first Frames frames were seen before the checkpoint was saved, so Fit skips them when resuming on the same frames
+func (m *MiniBatchKMeans) Fit(frames FrameIterator) error {
+	size := m.options.BatchSize
+	if size < 1 {
+		size = DefaultMiniBatchOptions().BatchSize
+	}
+
+	skip := m.Frames
+	batch := make([][]float64, 0, size)
+	for {
+		frame, err := frames.Next()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if frame != nil && skip > 0 {
+			skip--
+		} else if frame != nil {
+			batch = append(batch, frame)
+		}
+		if len(batch) < size && err != io.EOF {
+			continue
+		}
+
+		if err := m.Update(batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+
+		if err == io.EOF {
+			break
+		}
+		if m.options.Checkpoint != "" && m.options.CheckpointEvery > 0 && m.Batches%m.options.CheckpointEvery == 0 {
+			if err := m.Save(m.options.Checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.Centroids == nil {
+		return fmt.Errorf("no frames")
+	}
+	if m.options.Checkpoint != "" {
+		return m.Save(m.options.Checkpoint)
+	}
+	return nil
+}
+
+// Mixture assigns the frames to the closest centroids in a single pass
+// and returns the gaussians of the clusters without holding the frames in memory
+// The mixture can be used as it is or as the starting point of em (see GMMFromMixture)
+func (m *MiniBatchKMeans) Mixture(frames FrameIterator) (GaussianMixture, error) {
+	if m.Centroids == nil {
+		return nil, fmt.Errorf("the clusters are not initialised")
+	}
+
+	stats := make([]*RunningStats, m.K, m.K)
+	for c := range stats {
+		stats[c] = NewRunningStats(len(m.Variances), false)
+	}
+
+	total := 0
+	for {
+		frame, err := frames.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(frame) != len(m.Variances) {
+			return nil, fmt.Errorf("frame %d has %d coordinates instead of %d", total, len(frame), len(m.Variances))
+		}
+
+		stats[findClosestCentroid(m.Centroids, frame, m.Variances)].Add(frame)
+		total++
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no frames")
+	}
+
+	mixture := make(GaussianMixture, 0, m.K)
+	for _, s := range stats {
+		// em can't do anything with a gaussian without frames
+		if s.Count() == 0 {
+			continue
+		}
+
+		g := Gaussian{
+			Phi:          float64(s.Count()) / float64(total),
+			Expectations: s.Mean(),
+			Variances:    s.Variance(),
+		}
+		eps(&g.Variances, EPS)
+		mixture = append(mixture, g)
+	}
+	return mixture, nil
+}
+
+// GMMFromMixture runs em on the frames starting from the given mixture, e.g. the one of MiniBatchKMeans.Mixture
+// Unlike MiniBatchKMeans em needs all the frames in memory, so for corpora which don't fit pass a sample of them
+// The observer may be nil
+func GMMFromMixture(mfccsFloats [][]float64, mixture GaussianMixture, workers int, observer TrainingObserver) GaussianMixture {
+	X := make([]MfccClusterisable, len(mfccsFloats), len(mfccsFloats))
+	for i, mfcc := range mfccsFloats {
+		X[i] = MfccClusterisable{coefficients: mfcc, clusterID: -1}
+	}
+
+	initial := make(GaussianMixture, len(mixture), len(mixture))
+	for j, g := range mixture {
+		initial[j] = Gaussian{
+			Phi:          g.Phi,
+			Expectations: append([]float64{}, g.Expectations...),
+			Variances:    append([]float64{}, g.Variances...),
+		}
+	}
+	return em(X, nil, len(initial), initial, workers, observer)
+}
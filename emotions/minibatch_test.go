@@ -0,0 +1,92 @@
+package emotions
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiniBatchKMeans(t *testing.T) {
+	centres := [][]float64{{0, 0}, {10, 0}, {0, 10}}
+	random := rand.New(rand.NewSource(8))
+	points := make([][]float64, 3000, 3000)
+	for i := range points {
+		c := centres[i%3]
+		points[i] = []float64{c[0] + random.NormFloat64()*0.5, c[1] + random.NormFloat64()*0.5}
+	}
+
+	dir, err := ioutil.TempDir("", "minibatch")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	checkpoint := filepath.Join(dir, "kmeans.json")
+	options := DefaultMiniBatchOptions()
+	options.BatchSize = 100
+	options.Rand = rand.New(rand.NewSource(1))
+	options.Checkpoint = checkpoint
+	options.CheckpointEvery = 5
+
+	frames := make(chan []float64)
+	go func() {
+		for _, p := range points {
+			frames <- p
+		}
+		close(frames)
+	}()
+
+	m := NewMiniBatchKMeans(3, options)
+	assert.NoError(t, m.Fit(ChannelFrames(frames)))
+	assert.Equal(t, 3000, m.Frames)
+	assert.Equal(t, 30, m.Batches)
+
+	for _, c := range centres {
+		closest := math.Inf(1)
+		for _, centroid := range m.Centroids {
+			closest = math.Min(closest, math.Hypot(c[0]-centroid[0], c[1]-centroid[1]))
+		}
+		assert.True(t, closest < 0.3, "%v %v", c, m.Centroids)
+	}
+
+	loaded, err := LoadMiniBatchKMeans(checkpoint, options)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Centroids, loaded.Centroids)
+	assert.Equal(t, m.Counts, loaded.Counts)
+
+	mixture, err := loaded.Mixture(SliceFrames(points))
+	assert.NoError(t, err)
+	assert.Len(t, mixture, 3)
+	for _, g := range mixture {
+		assert.InDelta(t, 1.0/3, g.Phi, 1e-9)
+		assert.InDelta(t, 0.25, g.Variances[0], 0.05)
+	}
+
+	refined := GMMFromMixture(points[:600], mixture, 1, nil)
+	assert.Len(t, refined, 3)
+	// em works on its own copy
+	assert.InDelta(t, 1.0/3, mixture[0].Phi, 1e-9)
+
+	// stopping after the first 1000 frames and resuming on all of them ends where the uninterrupted run did
+	options.Rand = rand.New(rand.NewSource(1))
+	interrupted := NewMiniBatchKMeans(3, options)
+	assert.NoError(t, interrupted.Fit(SliceFrames(points[:1000])))
+
+	resumed, err := LoadMiniBatchKMeans(checkpoint, options)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, resumed.Frames)
+	assert.NoError(t, resumed.Fit(SliceFrames(points)))
+	assert.Equal(t, 3000, resumed.Frames)
+	assert.Equal(t, 30, resumed.Batches)
+	assert.Equal(t, m.Centroids, resumed.Centroids)
+	assert.Equal(t, m.Counts, resumed.Counts)
+}
+
+func TestMiniBatchKMeansShortBatch(t *testing.T) {
+	m := NewMiniBatchKMeans(3, DefaultMiniBatchOptions())
+	assert.Error(t, m.Fit(SliceFrames([][]float64{{1}, {2}})))
+	assert.Error(t, NewMiniBatchKMeans(2, DefaultMiniBatchOptions()).Fit(SliceFrames(nil)))
+}
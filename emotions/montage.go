@@ -0,0 +1,162 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Electrode is a labelled position on the scalp
+// The head is the unit sphere, x points to the right ear, y to the nose and z to the vertex (Cz)
+type Electrode struct {
+	Label string
+	X     float64
+	Y     float64
+	Z     float64
+	// Scalp is false for the channels with no 10-20 position (EOG, ECG...), which have no coordinates
+	Scalp bool
+}
+
+// standardPositions are the spherical coordinates (θ from the vertex, φ from the right ear, in degrees, BESA convention)
+// of the 10-20 positions and the 10-10 ones between them which commonly appear in recordings
+var standardPositions = map[string][2]float64{
+	"Fp1": {-92, -72}, "Fpz": {92, 90}, "Fp2": {92, 72},
+	"AF3": {-74, -65}, "AFz": {69, 90}, "AF4": {74, 65},
+	"F7": {-92, -36}, "F3": {-60, -51}, "Fz": {46, 90}, "F4": {60, 51}, "F8": {92, 36},
+	"FC5": {-69, -21}, "FC1": {-31, -46}, "FCz": {23, 90}, "FC2": {31, 46}, "FC6": {69, 21},
+	"T7": {-92, 0}, "C3": {-46, 0}, "Cz": {0, 0}, "C4": {46, 0}, "T8": {92, 0},
+	"CP5": {-69, 21}, "CP1": {-31, 46}, "CPz": {23, -90}, "CP2": {31, -46}, "CP6": {69, -21},
+	"P7": {-92, 36}, "P3": {-60, 51}, "Pz": {46, -90}, "P4": {60, -51}, "P8": {92, -36},
+	"PO3": {-74, 65}, "POz": {69, -90}, "PO4": {74, -65},
+	"O1": {-92, 72}, "Oz": {92, -90}, "O2": {92, -72},
+	"M1": {-115, 18}, "M2": {115, -18},
+}
+
+// positionAliases maps the old 10-20 names (and the earlobes) to the names in standardPositions
+var positionAliases = map[string]string{
+	"T3": "T7", "T4": "T8", "T5": "P7", "T6": "P8",
+	"A1": "M1", "A2": "M2",
+}
+
+// referenceSuffixes are the suffixes exports add to the labels for the reference of the channel, e.g. "Fp1-REF"
+var referenceSuffixes = []string{"-REF", "-LE", "-RE", "-AR", "-AVG", "-CAR"}
+
+// StandardElectrode returns the position of the 10-20 label
+// ignoring case, an "EEG " prefix and a reference suffix (see referenceSuffixes)
+func StandardElectrode(label string) (Electrode, bool) {
+	name := strings.TrimSpace(label)
+	if len(name) > 4 && strings.EqualFold(name[:4], "EEG ") {
+		name = strings.TrimSpace(name[4:])
+	}
+	for _, suffix := range referenceSuffixes {
+		if len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+			name = strings.TrimSpace(name[:len(name)-len(suffix)])
+			break
+		}
+	}
+
+	for alias, standard := range positionAliases {
+		if strings.EqualFold(name, alias) {
+			name = standard
+		}
+	}
+
+	for standard, angles := range standardPositions {
+		if !strings.EqualFold(name, standard) {
+			continue
+		}
+
+		θ := angles[0] * math.Pi / 180
+		φ := angles[1] * math.Pi / 180
+		return Electrode{
+			Label: label,
+			X:     math.Sin(θ) * math.Cos(φ),
+			Y:     math.Sin(θ) * math.Sin(φ),
+			Z:     math.Cos(θ),
+			Scalp: true,
+		}, true
+	}
+
+	return Electrode{}, false
+}
+
+// Montage maps channel indices to electrode positions
+type Montage struct {
+	Electrodes []Electrode
+}
+
+// NewMontage looks up the position of every channel label
+// The channels without a 10-20 position (EOG, ECG...) are not on the scalp and are left out of the pairs and the midline
+// It fails only if none of the channels is on the scalp
+func NewMontage(channels []string) (Montage, error) {
+	electrodes := make([]Electrode, len(channels), len(channels))
+	scalp := 0
+	for i, c := range channels {
+		e, ok := StandardElectrode(c)
+		if !ok {
+			electrodes[i] = Electrode{Label: c}
+			continue
+		}
+		electrodes[i] = e
+		scalp++
+	}
+
+	if scalp == 0 {
+		return Montage{}, fmt.Errorf("no electrode positions for the channels %v", channels)
+	}
+	return Montage{Electrodes: electrodes}, nil
+}
+
+// Index returns the index of the channel with the given label (or an alias of it) or -1
+func (m Montage) Index(label string) int {
+	e, ok := StandardElectrode(label)
+	if !ok {
+		return -1
+	}
+
+	for i := range m.Electrodes {
+		if m.Electrodes[i].Scalp && distance(m.Electrodes[i], e) < EPS {
+			return i
+		}
+	}
+	return -1
+}
+
+func distance(a, b Electrode) float64 {
+	return math.Sqrt((a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y) + (a.Z-b.Z)*(a.Z-b.Z))
+}
+
+func mirrored(e Electrode) Electrode {
+	e.X = -e.X
+	return e
+}
+
+// HemisphericPairs returns the (left, right) channel indices of the electrodes which are mirror images of each other
+// in the order of the left electrodes in the montage
+func (m Montage) HemisphericPairs() [][2]int {
+	var pairs [][2]int
+	for i, left := range m.Electrodes {
+		if !left.Scalp || left.X > -EPS {
+			continue
+		}
+
+		for j, right := range m.Electrodes {
+			if right.Scalp && right.X > EPS && distance(mirrored(left), right) < EPS {
+				pairs = append(pairs, [2]int{i, j})
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+// Midline returns the indices of the electrodes on the midline (Fz, Cz, Pz...) in the order of the montage
+func (m Montage) Midline() []int {
+	var midline []int
+	for i, e := range m.Electrodes {
+		if e.Scalp && math.Abs(e.X) < EPS {
+			midline = append(midline, i)
+		}
+	}
+	return midline
+}
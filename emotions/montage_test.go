@@ -0,0 +1,100 @@
+package emotions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMontage(t *testing.T) {
+	montage, err := NewMontage(DefaultEEGChannels)
+	assert.Nil(t, err)
+
+	// the couples the headset was originally paired by index
+	assert.Equal(t, [][2]int{
+		[2]int{0, 1},
+		[2]int{2, 3},
+		[2]int{5, 6},
+		[2]int{8, 9},
+		[2]int{11, 12},
+		[2]int{13, 14},
+		[2]int{15, 16},
+		[2]int{17, 18},
+	}, montage.HemisphericPairs())
+	assert.Equal(t, []int{4, 7, 10}, montage.Midline())
+	assert.Equal(t, 13, montage.Index("T7"))
+
+	// the channels of an export with reference suffixes and an eog channel
+	montage, err = NewMontage([]string{"Fp1-REF", "EOG", "Fp2-REF", "Cz-LE", "ECG", "A1"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][2]int{[2]int{0, 2}}, montage.HemisphericPairs())
+	assert.Equal(t, []int{3}, montage.Midline())
+	assert.False(t, montage.Electrodes[1].Scalp)
+	assert.Equal(t, 5, montage.Index("M1"))
+	assert.Equal(t, -1, montage.Index("EOG"))
+
+	_, err = NewMontage([]string{"EOG", "ECG"})
+	assert.NotNil(t, err)
+}
+
+func TestDEForChannelsWithEOG(t *testing.T) {
+	// an eog channel between the scalp channels doesn't stop the features
+	channels := []string{"Fp1", "EOG", "Fp2", "Cz"}
+	frame := make([]float64, len(channels)*len(EEGBands), len(channels)*len(EEGBands))
+	for i := range frame {
+		frame[i] = float64(i)
+	}
+
+	de, err := GetDEForChannels([][]float64{frame}, channels)
+	assert.Nil(t, err)
+	assert.Len(t, de[0], 2*len(EEGBands))
+	assert.Equal(t, float64(2*len(EEGBands)), de[0][0])
+	assert.Equal(t, frame[3*len(EEGBands)], de[0][len(EEGBands)])
+
+	asymmetry, err := NewAsymmetry(channels)
+	assert.Nil(t, err)
+	assert.Equal(t, [][2]string{{"Fp1", "Fp2"}}, asymmetry.Hemispheric)
+}
+
+func TestReference(t *testing.T) {
+	rec, err := NewEEGRecording([][]float64{
+		[]float64{1, 2, 3},
+		[]float64{3, 4, 5},
+		[]float64{2, 0, 1},
+		[]float64{4, 2, 3},
+	}, DefaultEEGSampleRate, []string{"C3", "C4", "A1", "A2"}, nil)
+	assert.Nil(t, err)
+
+	average, err := CommonAverageReference(rec)
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{-1.5, 0, 0}, average.Data[0])
+
+	mastoids, err := LinkedMastoidsReference(rec)
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{-2, 1, 1}, mastoids.Data[0])
+
+	bipolar, err := BipolarReference(rec, [][2]string{{"C4", "C3"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"C4-C3"}, bipolar.Channels)
+	assert.Equal(t, [][]float64{[]float64{2, 2, 2}}, bipolar.Data)
+
+	picked, err := PickChannels(rec, "M2", "C3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"A2", "C3"}, picked.Channels)
+
+	_, err = PickChannels(rec, "O1")
+	assert.NotNil(t, err)
+
+	// the blinks of the EOG channel stay out of the average and the EOG channel itself
+	withEOG, err := NewEEGRecording(append(rec.Data, []float64{100, -100, 50}), DefaultEEGSampleRate, []string{"C3", "C4", "A1", "A2", "EOG"}, nil)
+	assert.Nil(t, err)
+	average, err = CommonAverageReference(withEOG)
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{-1.5, 0, 0}, average.Data[0])
+	assert.Equal(t, []float64{100, -100, 50}, average.Data[4])
+
+	eog, err := PickChannels(withEOG, "EOG")
+	assert.Nil(t, err)
+	_, err = CommonAverageReference(eog)
+	assert.NotNil(t, err)
+}
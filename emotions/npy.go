@@ -0,0 +1,321 @@
+package emotions
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const npyMagic = "\x93NUMPY"
+
+// WriteNpyTo writes the features as a version 1.0 .npy array of little-endian float64 with shape (frames, dims)
+func WriteNpyTo(w io.Writer, features [][]float64) error {
+	cols := 0
+	if len(features) > 0 {
+		cols = len(features[0])
+	}
+
+	header := fmt.Sprintf("{'descr': '<f8', 'fortran_order': False, 'shape': (%d, %d), }", len(features), cols)
+	// magic + version + header length + header + newline should be aligned to 64 bytes
+	padding := 64 - (len(npyMagic)+4+len(header)+1)%64
+	header += strings.Repeat(" ", padding%64) + "\n"
+
+	if _, err := io.WriteString(w, npyMagic+"\x01\x00"); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(header))); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	for i := range features {
+		if len(features[i]) != cols {
+			return fmt.Errorf("row %d has %d columns instead of %d", i, len(features[i]), cols)
+		}
+		if err := binary.Write(w, binary.LittleEndian, features[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// npyHeader holds the parts of the .npy header we understand
+type npyHeader struct {
+	descr        string
+	fortranOrder bool
+	shape        []int
+}
+
+// parseNpyHeader parses the python dict literal of the .npy header
+func parseNpyHeader(header string) (npyHeader, error) {
+	var h npyHeader
+
+	value := func(key string) (string, error) {
+		i := strings.Index(header, "'"+key+"'")
+		if i == -1 {
+			return "", fmt.Errorf("missing %s in npy header", key)
+		}
+		rest := strings.TrimSpace(header[i+len(key)+2:])
+		if !strings.HasPrefix(rest, ":") {
+			return "", fmt.Errorf("malformed npy header: %s", header)
+		}
+		return strings.TrimSpace(rest[1:]), nil
+	}
+
+	descr, err := value("descr")
+	if err != nil {
+		return h, err
+	}
+	if len(descr) < 2 || descr[0] != '\'' {
+		return h, fmt.Errorf("malformed descr in npy header: %s", header)
+	}
+	h.descr = descr[1 : 1+strings.Index(descr[1:], "'")]
+
+	fortranOrder, err := value("fortran_order")
+	if err != nil {
+		return h, err
+	}
+	h.fortranOrder = strings.HasPrefix(fortranOrder, "True")
+
+	shape, err := value("shape")
+	if err != nil {
+		return h, err
+	}
+	end := strings.Index(shape, ")")
+	if !strings.HasPrefix(shape, "(") || end == -1 {
+		return h, fmt.Errorf("malformed shape in npy header: %s", header)
+	}
+	for _, s := range strings.Split(shape[1:end], ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return h, fmt.Errorf("malformed shape in npy header: %s", header)
+		}
+		h.shape = append(h.shape, n)
+	}
+
+	return h, nil
+}
+
+// ReadNpyFrom reads a one or two dimensional .npy array of floats or integers
+// A one dimensional array is read as a single column
+func ReadNpyFrom(r io.Reader) ([][]float64, error) {
+	preamble := make([]byte, len(npyMagic)+2, len(npyMagic)+2)
+	if _, err := io.ReadFull(r, preamble); err != nil {
+		return nil, err
+	}
+	if string(preamble[:len(npyMagic)]) != npyMagic {
+		return nil, fmt.Errorf("not an npy file")
+	}
+
+	var headerLen int
+	switch preamble[len(npyMagic)] {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	default:
+		return nil, fmt.Errorf("unsupported npy version %d", preamble[len(npyMagic)])
+	}
+
+	rawHeader := make([]byte, headerLen, headerLen)
+	if _, err := io.ReadFull(r, rawHeader); err != nil {
+		return nil, err
+	}
+	header, err := parseNpyHeader(string(rawHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	var rows, cols int
+	switch len(header.shape) {
+	case 1:
+		rows, cols = header.shape[0], 1
+	case 2:
+		rows, cols = header.shape[0], header.shape[1]
+	default:
+		return nil, fmt.Errorf("expected a one or two dimensional array, got shape %v", header.shape)
+	}
+	if err := checkMatrixShape(rows, cols); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if strings.HasPrefix(header.descr, ">") {
+		order = binary.BigEndian
+	}
+
+	read, err := npyReader(strings.TrimLeft(header.descr, "<>|="), order)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, rows*cols, rows*cols)
+	for i := range values {
+		if values[i], err = read(r); err != nil {
+			return nil, err
+		}
+	}
+
+	features := make([][]float64, rows, rows)
+	for i := range features {
+		features[i] = make([]float64, cols, cols)
+		for j := range features[i] {
+			if header.fortranOrder {
+				features[i][j] = values[j*rows+i]
+			} else {
+				features[i][j] = values[i*cols+j]
+			}
+		}
+	}
+
+	return features, nil
+}
+
+// npyReader returns a function which reads a single value of the given numpy type
+func npyReader(descr string, order binary.ByteOrder) (func(io.Reader) (float64, error), error) {
+	switch descr {
+	case "f8":
+		return func(r io.Reader) (float64, error) {
+			var v float64
+			err := binary.Read(r, order, &v)
+			return v, err
+		}, nil
+	case "f4":
+		return func(r io.Reader) (float64, error) {
+			var v float32
+			err := binary.Read(r, order, &v)
+			return float64(v), err
+		}, nil
+	case "i8":
+		return func(r io.Reader) (float64, error) {
+			var v int64
+			err := binary.Read(r, order, &v)
+			return float64(v), err
+		}, nil
+	case "i4":
+		return func(r io.Reader) (float64, error) {
+			var v int32
+			err := binary.Read(r, order, &v)
+			return float64(v), err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported npy type %s", descr)
+	}
+}
+
+// WriteNpy writes the features into a .npy file
+func WriteNpy(filename string, features [][]float64) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	if err := WriteNpyTo(w, features); err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadNpy reads the features from a .npy file
+func ReadNpy(filename string) ([][]float64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	features, err := ReadNpyFrom(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return features, nil
+}
+
+// WriteNpz writes every tagged matrix as <tag>.npy into an (uncompressed) .npz archive
+func WriteNpz(filename string, features []Tagged) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(file)
+	for _, f := range features {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: f.Tag + ".npy", Method: zip.Store})
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if err := WriteNpyTo(w, f.Data); err != nil {
+			file.Close()
+			return fmt.Errorf("%s: %s: %s", filename, f.Tag, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadNpz reads all the arrays in an .npz archive, the tags are the names of the arrays
+func ReadNpz(filename string) ([]Tagged, error) {
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	features := make([]Tagged, 0, len(archive.File))
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ReadNpyFrom(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", filename, f.Name, err)
+		}
+
+		features = append(features, Tagged{
+			Tag:  strings.TrimSuffix(path.Base(f.Name), ".npy"),
+			Data: data,
+		})
+	}
+
+	return features, nil
+}
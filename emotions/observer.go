@@ -0,0 +1,81 @@
+package emotions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TrainingIteration is the state of KMeans or em after an iteration
+// The parameters belong to the algorithm, so they must not be changed or kept after the call
+type TrainingIteration struct {
+	// Algorithm is "kmeans" or "em"
+	Algorithm string
+	// Run is the restart of KMeans (see KMeansOptions.NInit)
+	Run       int
+	Iteration int
+	// RSS is the score of kmeans
+	RSS float64 `json:",omitempty"`
+	// LogLikelihood is the score of em
+	LogLikelihood float64         `json:",omitempty"`
+	Centroids     [][]float64     `json:",omitempty"`
+	Mixture       GaussianMixture `json:",omitempty"`
+}
+
+// TrainingObserver follows the iterations of KMeans and em
+type TrainingObserver interface {
+	IterationStart(algorithm string, run int, iteration int)
+	IterationEnd(iteration TrainingIteration)
+}
+
+// observerOrSilent returns the observer or a silent one if it is nil
+func observerOrSilent(observer TrainingObserver) TrainingObserver {
+	if observer == nil {
+		return SilentObserver{}
+	}
+	return observer
+}
+
+// SilentObserver ignores the training
+type SilentObserver struct{}
+
+func (SilentObserver) IterationStart(string, int, int) {}
+
+func (SilentObserver) IterationEnd(TrainingIteration) {}
+
+// LoggingObserver writes a line with the score of every iteration
+type LoggingObserver struct {
+	Writer io.Writer
+}
+
+func (LoggingObserver) IterationStart(string, int, int) {}
+
+func (o LoggingObserver) IterationEnd(it TrainingIteration) {
+	switch it.Algorithm {
+	case "kmeans":
+		fmt.Fprintf(o.Writer, "kmeans run %d iteration %d RSS: %f\n", it.Run, it.Iteration, it.RSS)
+	default:
+		fmt.Fprintf(o.Writer, "%s iteration %d likelihood: %f\n", it.Algorithm, it.Iteration, it.LogLikelihood)
+	}
+}
+
+// TraceObserver writes every iteration with its parameters as a line of json
+// The first write error stops the trace and is kept in Err
+type TraceObserver struct {
+	encoder *json.Encoder
+	Err     error
+}
+
+// NewTraceObserver writes the trace to the writer, e.g. a file per training
+func NewTraceObserver(writer io.Writer) *TraceObserver {
+	return &TraceObserver{encoder: json.NewEncoder(writer)}
+}
+
+func (o *TraceObserver) IterationStart(string, int, int) {}
+
+func (o *TraceObserver) IterationEnd(it TrainingIteration) {
+	if o.Err != nil {
+		return
+	}
+	o.Err = o.encoder.Encode(it)
+}
@@ -0,0 +1,65 @@
+package emotions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingObserver struct {
+	starts     map[string]int
+	iterations []TrainingIteration
+}
+
+func (o *countingObserver) IterationStart(algorithm string, run int, iteration int) {
+	o.starts[algorithm]++
+}
+
+func (o *countingObserver) IterationEnd(it TrainingIteration) {
+	o.iterations = append(o.iterations, it)
+}
+
+func TestTrainingObserver(t *testing.T) {
+	points := syntheticClusters(rand.New(rand.NewSource(6)), 300, 2, 2)
+
+	observer := &countingObserver{starts: make(map[string]int)}
+	options := DefaultKMeansOptions()
+	options.Rand = rand.New(rand.NewSource(1))
+	options.Observer = observer
+	GMMWithOptions(points, 2, options)
+
+	assert.True(t, observer.starts["kmeans"] > 0)
+	assert.True(t, observer.starts["em"] > 0)
+	assert.Equal(t, observer.starts["kmeans"]+observer.starts["em"], len(observer.iterations))
+	assert.Equal(t, "kmeans", observer.iterations[0].Algorithm)
+	assert.Len(t, observer.iterations[0].Centroids, 2)
+	last := observer.iterations[len(observer.iterations)-1]
+	assert.Equal(t, "em", last.Algorithm)
+	assert.Len(t, last.Mixture, 2)
+
+	var trace bytes.Buffer
+	options.Rand = rand.New(rand.NewSource(1))
+	options.Observer = NewTraceObserver(&trace)
+	GMMWithOptions(points, 2, options)
+	assert.Nil(t, options.Observer.(*TraceObserver).Err)
+
+	lines := 0
+	scanner := bufio.NewScanner(&trace)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var it TrainingIteration
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &it))
+		assert.Equal(t, observer.iterations[lines].Algorithm, it.Algorithm)
+		lines++
+	}
+	assert.Equal(t, len(observer.iterations), lines)
+
+	var log bytes.Buffer
+	LoggingObserver{Writer: &log}.IterationEnd(TrainingIteration{Algorithm: "kmeans", Iteration: 3, RSS: 1.5})
+	assert.True(t, strings.HasPrefix(log.String(), "kmeans run 0 iteration 3 RSS: 1.5"))
+}
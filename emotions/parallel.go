@@ -0,0 +1,54 @@
+package emotions
+
+import (
+	"runtime"
+	"sync"
+)
+
+// shardSize is the number of points in a shard of the parallel loops
+// The shards don't depend on the number of workers, so adding up their partial sums in order
+// gives the same floats with any number of workers
+const shardSize = 256
+
+// numShards returns the number of shards of n points
+func numShards(n int) int {
+	return (n + shardSize - 1) / shardSize
+}
+
+// numWorkers returns the given number of workers or GOMAXPROCS if it is not positive
+func numWorkers(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// parallel calls f for every shard [from, to) of n points on at most workers goroutines
+func parallel(n int, workers int, f func(shard int, from int, to int)) {
+	shards := numShards(n)
+	workers = Min(numWorkers(workers), shards)
+	if workers <= 1 {
+		for s := 0; s < shards; s++ {
+			f(s, s*shardSize, Min((s+1)*shardSize, n))
+		}
+		return
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range next {
+				f(s, s*shardSize, Min((s+1)*shardSize, n))
+			}
+		}()
+	}
+
+	for s := 0; s < shards; s++ {
+		next <- s
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,161 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+)
+
+func freqToBark(freq float64) float64 {
+	return 6 * math.Asinh(freq/600.0)
+}
+
+func barkToFreq(bark float64) float64 {
+	return 600 * math.Sinh(bark/6.0)
+}
+
+// criticalBandWeight is the masking curve of a critical band (Hermansky 1990)
+// d is the distance in bark from the center of the band
+func criticalBandWeight(d float64) float64 {
+	switch {
+	case d < -1.3 || d > 2.5:
+		return 0
+	case d < -0.5:
+		return math.Pow(10, 2.5*(d+0.5))
+	case d <= 0.5:
+		return 1
+	default:
+		return math.Pow(10, -(d - 0.5))
+	}
+}
+
+// equalLoudness approximates the sensitivity of the human hearing at the given frequency
+func equalLoudness(freq float64) float64 {
+	w := 2 * math.Pi * freq
+	w2 := w * w
+	return (w2 + 56.8e6) * w2 * w2 / ((w2 + 6.3e6) * (w2 + 6.3e6) * (w2 + 0.38e9))
+}
+
+// barkScale takes the fourier coefficients for one frame and returns the energies in M critical bands
+// equally spaced on the bark scale between 0 and sampleRate/2
+func barkScale(coefficients []Complex, sampleRate int, M int) []float64 {
+	maxBark := freqToBark(float64(sampleRate) / 2.0)
+	step := maxBark / float64(M-1)
+
+	bands := make([]float64, M, M)
+	for i := range coefficients {
+		bark := freqToBark(IndToFreq(i, sampleRate, len(coefficients)))
+		power := Power(coefficients[i])
+		for m := 0; m < M; m++ {
+			bands[m] += power * criticalBandWeight(bark-float64(m)*step)
+		}
+	}
+
+	return bands
+}
+
+// rastaFilter band-passes the trajectory of every log critical band through time
+// H(z) = 0.1 * (2 + z^-1 - z^-3 - 2z^-4) / (1 - 0.98z^-1)
+// which removes the slowly varying channel and the fast frame to frame changes
+func rastaFilter(logBands [][]float64) {
+	if len(logBands) == 0 {
+		return
+	}
+
+	numerator := []float64{0.2, 0.1, 0, -0.1, -0.2}
+	for m := 0; m < len(logBands[0]); m++ {
+		x := make([]float64, len(logBands), len(logBands))
+		for t := range logBands {
+			x[t] = logBands[t][m]
+		}
+
+		previous := 0.0
+		for t := range logBands {
+			y := 0.98 * previous
+			for j, b := range numerator {
+				// we pretend the signal was constant before the first frame
+				y += b * x[Max(t-j, 0)]
+			}
+			logBands[t][m] = y
+			previous = y
+		}
+	}
+}
+
+// auditorySpectrum applies equal loudness pre-emphasis and the cube root intensity-loudness compression to the critical bands
+// The first and the last band are copied from their neighbours since they lie beyond the reliable part of the spectrum
+func auditorySpectrum(bands []float64, sampleRate int) []float64 {
+	M := len(bands)
+	step := freqToBark(float64(sampleRate)/2.0) / float64(M-1)
+
+	spectrum := make([]float64, M, M)
+	for m := 0; m < M; m++ {
+		spectrum[m] = math.Cbrt(bands[m] * equalLoudness(barkToFreq(float64(m)*step)))
+	}
+	spectrum[0] = spectrum[1]
+	spectrum[M-1] = spectrum[M-2]
+
+	return spectrum
+}
+
+// spectrumToAutocorrelation returns the first p+1 autocorrelation coefficients of the given power spectrum
+// by taking the inverse dft of its even (real and symmetric) extension
+func spectrumToAutocorrelation(spectrum []float64, p int) []float64 {
+	M := len(spectrum)
+	r := make([]float64, p+1, p+1)
+	for k := 0; k <= p; k++ {
+		r[k] = spectrum[0] + math.Pow(-1, float64(k))*spectrum[M-1]
+		for m := 1; m < M-1; m++ {
+			r[k] += 2 * spectrum[m] * math.Cos(math.Pi*float64(k)*float64(m)/float64(M-1))
+		}
+		r[k] /= float64(2 * (M - 1))
+	}
+	return r
+}
+
+// PLPs returns the order p perceptual linear prediction cepstra computed from M critical bands,
+// their first and second derivatives
+// If rasta is true the log critical bands are RASTA filtered through time (RASTA-PLP)
+// like MFCCs the first of the C coefficients is replaced with the log energy of the frame
+// It panics unless p and C are positive and there are at least 3 critical bands
+func PLPs(wf WavFile, p int, C int, M int, rasta bool) [][]float64 {
+	checkLPCOrder(p, C)
+	if M < 3 {
+		// the auditory spectrum copies the first and last bands from their neighbours
+		panic(fmt.Sprintf("%d critical bands instead of at least 3", M))
+	}
+	frames := CutWavFileIntoFrames(wf)
+	sampleRate := wf.GetSampleRate()
+
+	bands := make([][]float64, len(frames), len(frames))
+	energies := make([]float64, len(frames), len(frames))
+	for i, frame := range frames {
+		frameCoefficients, energy := FftReal(frame)
+		energies[i] = safeLog(energy)
+		bands[i] = barkScale(frameCoefficients, sampleRate, M)
+	}
+
+	if rasta {
+		for i := range bands {
+			for m := range bands[i] {
+				bands[i][m] = safeLog(bands[i][m])
+			}
+		}
+		rastaFilter(bands)
+		for i := range bands {
+			for m := range bands[i] {
+				bands[i][m] = math.Exp(bands[i][m])
+			}
+		}
+	}
+
+	plps := make([][]float64, len(frames), len(frames))
+	for i := range bands {
+		r := spectrumToAutocorrelation(auditorySpectrum(bands[i], sampleRate), p)
+		a, _, predictionError := LevinsonDurbin(r, p)
+
+		plps[i] = lpcToCepstrum(a, math.Sqrt(predictionError), C)
+		plps[i][0] = energies[i]
+	}
+
+	return MFCCcDouble(plps)
+}
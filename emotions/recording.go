@@ -0,0 +1,129 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// DefaultEEGSampleRate is the sample rate of the headset the eeg recordings were made with
+const DefaultEEGSampleRate = 500
+
+// DefaultEEGUnit is the unit of the samples of the headset
+const DefaultEEGUnit = "µV"
+
+// DefaultEEGChannels are the 10-20 labels of the 19 electrodes of the headset in the order they are recorded
+// The hemispheric pairs are next to each other and Fz, Cz and Pz come after the frontal, first central and parietal pairs
+var DefaultEEGChannels = []string{
+	"Fp1", "Fp2", "F7", "F8", "Fz", "F3", "F4", "Cz", "C3", "C4",
+	"Pz", "P3", "P4", "T3", "T4", "T5", "T6", "O1", "O2",
+}
+
+// EEGRecording is a multichannel eeg recording
+// All the eeg features take the frequencies from its sample rate and the electrode positions from its channel labels
+type EEGRecording struct {
+	// Start is the wall clock time of the first sample, which the files store without a time zone
+	// All the readers put it in UTC, so the starts and the events of different formats can be compared
+	Start      time.Time
+	SampleRate int
+	// Channels are the labels of the electrodes, e.g. Fp1 or Cz
+	Channels []string
+	// Units are the physical units of every channel, e.g. µV
+	Units []string
+	// Data is channels x samples
+	Data [][]float64
+}
+
+// NewEEGRecording checks that the data (channels x samples) matches the channels
+// If units is nil every channel is in DefaultEEGUnit
+func NewEEGRecording(data [][]float64, sampleRate int, channels []string, units []string) (EEGRecording, error) {
+	if sampleRate <= 0 {
+		return EEGRecording{}, fmt.Errorf("invalid sample rate: %d", sampleRate)
+	}
+	if len(channels) != len(data) {
+		return EEGRecording{}, fmt.Errorf("%d channel labels for %d channels", len(channels), len(data))
+	}
+	if units == nil {
+		units = channelUnits(len(data))
+	}
+	if len(units) != len(data) {
+		return EEGRecording{}, fmt.Errorf("%d units for %d channels", len(units), len(data))
+	}
+	for i := range data {
+		if len(data[i]) != len(data[0]) {
+			return EEGRecording{}, fmt.Errorf("channel %s has %d samples instead of %d", channels[i], len(data[i]), len(data[0]))
+		}
+	}
+
+	return EEGRecording{
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Units:      units,
+		Data:       data,
+	}, nil
+}
+
+func channelUnits(n int) []string {
+	units := make([]string, n, n)
+	for i := range units {
+		units[i] = DefaultEEGUnit
+	}
+	return units
+}
+
+// ChannelIndex returns the index of the channel with the given label (ignoring case) or -1
+func (r EEGRecording) ChannelIndex(label string) int {
+	return channelIndex(r.Channels, label)
+}
+
+func channelIndex(channels []string, label string) int {
+	for i, c := range channels {
+		if strings.EqualFold(c, label) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Duration returns the length of the recording
+func (r EEGRecording) Duration() time.Duration {
+	if len(r.Data) == 0 || r.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(len(r.Data[0])) / float64(r.SampleRate) * float64(time.Second))
+}
+
+// Between returns the part of the recording between the two moments
+// The first sample is the first one at or after from and the last one is before to
+func (r EEGRecording) Between(from time.Time, to time.Time) EEGRecording {
+	// a cut from before the start of the recording starts with its first sample
+	first := Max(int(math.Ceil(from.Sub(r.Start).Seconds()*float64(r.SampleRate))), 0)
+	last := int(math.Floor(to.Sub(r.Start).Seconds() * float64(r.SampleRate)))
+
+	cut := r
+	cut.Start = r.Start.Add(time.Duration(float64(first) / float64(r.SampleRate) * float64(time.Second)))
+	cut.Data = make([][]float64, len(r.Data), len(r.Data))
+	for i := range r.Data {
+		begin := Min(first, len(r.Data[i]))
+		end := Min(Max(last, begin), len(r.Data[i]))
+		cut.Data[i] = r.Data[i][begin:end]
+	}
+
+	return cut
+}
+
+// GetFourierForRecording returns the feature vectors numFrames x (numEl * len(EEGBands)) of the recording (see getFourier)
+func GetFourierForRecording(rec EEGRecording, frameLen int, frameStep int) [][]float64 {
+	return getFourier(rec.Data, rec.SampleRate, frameLen, frameStep)
+}
+
+// GetFeatureVectorForRecording returns the mean power in each of EEGBands for each of the electrodes in the recording
+func GetFeatureVectorForRecording(rec EEGRecording, frameLen int, frameStep int) [][]float64 {
+	features := make([][]float64, len(rec.Data), len(rec.Data))
+	for i, d := range rec.Data {
+		features[i] = getElectrodeWavesDistribution(d, rec.SampleRate, frameLen, frameStep)
+	}
+
+	return features
+}
@@ -0,0 +1,63 @@
+package emotions
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sineRecording(freq float64, sampleRate int, seconds int, channels []string) EEGRecording {
+	data := make([][]float64, len(channels), len(channels))
+	for c := range data {
+		data[c] = make([]float64, sampleRate*seconds, sampleRate*seconds)
+		for i := range data[c] {
+			data[c][i] = math.Sin(2 * math.Pi * freq * float64(i) / float64(sampleRate))
+		}
+	}
+
+	rec, _ := NewEEGRecording(data, sampleRate, channels, nil)
+	return rec
+}
+
+func TestFourierForRecording(t *testing.T) {
+	for _, sampleRate := range []int{250, 500, 1000} {
+		// a 10Hz sine should put most of its power in α for any sample rate
+		rec := sineRecording(10.0, sampleRate, 4, []string{"Cz", "Pz"})
+		features := GetFourierForRecording(rec, 1000, 500)
+
+		assert.Equal(t, 2*len(EEGBands), len(features[0]))
+		for _, f := range features {
+			for c := 0; c < 2; c++ {
+				waves := f[c*len(EEGBands) : (c+1)*len(EEGBands)]
+				α := EEGBands.IndexOf("α")
+				for w := range waves {
+					if w != α {
+						assert.True(t, waves[α] > waves[w], "sample rate %d: %v", sampleRate, waves)
+					}
+				}
+			}
+		}
+	}
+
+	_, err := NewEEGRecording([][]float64{[]float64{1, 2}}, 500, []string{"Cz", "Pz"}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestRecordingBetween(t *testing.T) {
+	rec := sineRecording(10.0, 10, 2, []string{"Cz"})
+	rec.Start = time.Date(2019, 2, 28, 10, 0, 0, 0, time.UTC)
+	for i := range rec.Data[0] {
+		rec.Data[0][i] = float64(i)
+	}
+
+	cut := rec.Between(rec.Start.Add(250*time.Millisecond), rec.Start.Add(time.Second))
+	assert.Equal(t, rec.Start.Add(300*time.Millisecond), cut.Start)
+	assert.Equal(t, []float64{3, 4, 5, 6, 7, 8, 9}, cut.Data[0])
+
+	// a cut from before the start begins with the first sample and at the start
+	cut = rec.Between(rec.Start.Add(-time.Second), rec.Start.Add(500*time.Millisecond))
+	assert.Equal(t, rec.Start, cut.Start)
+	assert.Equal(t, []float64{0, 1, 2, 3, 4}, cut.Data[0])
+}
@@ -0,0 +1,148 @@
+package emotions
+
+import (
+	"fmt"
+)
+
+// DoubleBanana is the longitudinal bipolar chain of the 10-20 system
+var DoubleBanana = [][2]string{
+	{"Fp1", "F7"}, {"F7", "T3"}, {"T3", "T5"}, {"T5", "O1"},
+	{"Fp2", "F8"}, {"F8", "T4"}, {"T4", "T6"}, {"T6", "O2"},
+	{"Fp1", "F3"}, {"F3", "C3"}, {"C3", "P3"}, {"P3", "O1"},
+	{"Fp2", "F4"}, {"F4", "C4"}, {"C4", "P4"}, {"P4", "O2"},
+	{"Fz", "Cz"}, {"Cz", "Pz"},
+}
+
+// findChannel returns the index of the channel with the given label, also matching the aliases of 10-20 labels
+func findChannel(rec EEGRecording, label string) int {
+	if i := rec.ChannelIndex(label); i != -1 {
+		return i
+	}
+
+	target, ok := StandardElectrode(label)
+	if !ok {
+		return -1
+	}
+
+	for i, c := range rec.Channels {
+		if e, ok := StandardElectrode(c); ok && distance(e, target) < EPS {
+			return i
+		}
+	}
+	return -1
+}
+
+// withChannels returns a recording with the same timing as rec and the given channels
+func withChannels(rec EEGRecording, channels []string, units []string, data [][]float64) EEGRecording {
+	return EEGRecording{
+		Start:      rec.Start,
+		SampleRate: rec.SampleRate,
+		Channels:   channels,
+		Units:      units,
+		Data:       data,
+	}
+}
+
+func unitOf(rec EEGRecording, i int) string {
+	if i < len(rec.Units) {
+		return rec.Units[i]
+	}
+	return DefaultEEGUnit
+}
+
+// PickChannels returns a recording with only the channels with the given labels in the given order
+func PickChannels(rec EEGRecording, labels ...string) (EEGRecording, error) {
+	channels := make([]string, len(labels), len(labels))
+	units := make([]string, len(labels), len(labels))
+	data := make([][]float64, len(labels), len(labels))
+
+	for i, label := range labels {
+		c := findChannel(rec, label)
+		if c == -1 {
+			return EEGRecording{}, fmt.Errorf("missing channel %s in %v", label, rec.Channels)
+		}
+		channels[i] = rec.Channels[c]
+		units[i] = unitOf(rec, c)
+		data[i] = rec.Data[c]
+	}
+
+	return withChannels(rec, channels, units, data), nil
+}
+
+// subtractReference returns a copy of the recording with reference subtracted from every channel
+func subtractReference(rec EEGRecording, reference []float64) EEGRecording {
+	data := make([][]float64, len(rec.Data), len(rec.Data))
+	for i := range rec.Data {
+		data[i] = minused(rec.Data[i], reference)
+	}
+
+	return withChannels(rec, rec.Channels, rec.Units, data)
+}
+
+// CommonAverageReference re-references every scalp channel to the mean of the scalp channels (see Montage)
+// The channels off the scalp (EOG, ECG...) are left as they are, so blinks and heart beats don't leak into the eeg channels
+func CommonAverageReference(rec EEGRecording) (EEGRecording, error) {
+	if len(rec.Data) == 0 {
+		return rec, nil
+	}
+	montage, err := NewMontage(rec.Channels)
+	if err != nil {
+		return EEGRecording{}, err
+	}
+
+	reference := make([]float64, len(rec.Data[0]), len(rec.Data[0]))
+	scalp := 0
+	for i, e := range montage.Electrodes {
+		if e.Scalp {
+			add(&reference, rec.Data[i])
+			scalp++
+		}
+	}
+	divide(&reference, float64(scalp))
+
+	data := make([][]float64, len(rec.Data), len(rec.Data))
+	for i, e := range montage.Electrodes {
+		data[i] = rec.Data[i]
+		if e.Scalp {
+			data[i] = minused(rec.Data[i], reference)
+		}
+	}
+	return withChannels(rec, rec.Channels, rec.Units, data), nil
+}
+
+// LinkedMastoidsReference re-references every channel to the mean of the two mastoids (M1 and M2, or the earlobes A1 and A2)
+func LinkedMastoidsReference(rec EEGRecording) (EEGRecording, error) {
+	left := findChannel(rec, "M1")
+	right := findChannel(rec, "M2")
+	if left == -1 || right == -1 {
+		return EEGRecording{}, fmt.Errorf("missing mastoid channels in %v", rec.Channels)
+	}
+
+	reference := make([]float64, len(rec.Data[left]), len(rec.Data[left]))
+	add(&reference, rec.Data[left])
+	add(&reference, rec.Data[right])
+	divide(&reference, 2)
+
+	return subtractReference(rec, reference), nil
+}
+
+// BipolarReference returns a recording with a channel "a-b" for every couple (a, b) in the chain (e.g. DoubleBanana)
+func BipolarReference(rec EEGRecording, chain [][2]string) (EEGRecording, error) {
+	channels := make([]string, len(chain), len(chain))
+	units := make([]string, len(chain), len(chain))
+	data := make([][]float64, len(chain), len(chain))
+
+	for i, c := range chain {
+		a := findChannel(rec, c[0])
+		b := findChannel(rec, c[1])
+		if a == -1 || b == -1 {
+			return EEGRecording{}, fmt.Errorf("missing channels for %s-%s in %v", c[0], c[1], rec.Channels)
+		}
+
+		channels[i] = rec.Channels[a] + "-" + rec.Channels[b]
+		units[i] = unitOf(rec, a)
+		data[i] = minused(rec.Data[a], rec.Data[b])
+	}
+
+	return withChannels(rec, channels, units, data), nil
+}
@@ -148,34 +148,44 @@ func AverageSlice(x [][]float64, average int) [][]float64 {
 	return averagedSlice
 }
 
-var ElectrodeCouples [][2]int = [][2]int{
-	[2]int{0, 1},
-	[2]int{2, 3},
-	[2]int{5, 6},
-	[2]int{8, 9},
-	[2]int{11, 12},
-	[2]int{13, 14},
-	[2]int{15, 16},
-	[2]int{17, 18},
+// GetDE takes the feature vectors of getFourier for a recording with DefaultEEGChannels
+// and returns the asymmetry of every hemispheric electrode couple followed by the midline electrodes
+// These are log power differences, the features on differential entropy are in Asymmetry
+func GetDE(data [][]float64) [][]float64 {
+	result, err := GetDEForChannels(data, DefaultEEGChannels)
+	if err != nil {
+		panic(err)
+	}
+	return result
 }
 
-func GetDE(data [][]float64) [][]float64 {
+// GetDEForChannels takes the feature vectors of getFourier for a recording with the given channels
+// and returns |left - right| for every wave of the hemispheric couples followed by the waves of the midline electrodes
+// both of which are derived from the 10-20 labels of the channels (see Montage)
+func GetDEForChannels(data [][]float64, channels []string) ([][]float64, error) {
+	montage, err := NewMontage(channels)
+	if err != nil {
+		return nil, err
+	}
+
+	couples := montage.HemisphericPairs()
+	midline := montage.Midline()
+
 	result := make([][]float64, len(data), len(data))
-	n := len(ElectrodeCouples) * len(waveRanges)
+	n := len(couples) * len(EEGBands)
 
 	for i := 0; i < len(result); i++ {
-		result[i] = make([]float64, n+3*len(waveRanges), n+3*len(waveRanges))
-		for j, c := range ElectrodeCouples {
-			for k := 0; k < len(waveRanges); k++ {
-				// fmt.Printf("result[%d][%d] = data[%d][%d] - data[%d][%d]\n", i, k+j*len(waveRanges), i, k+(c[0]*len(waveRanges)), i, k+(c[1]*len(waveRanges)))
-				result[i][k+j*len(waveRanges)] = math.Abs(data[i][k+(c[0]*len(waveRanges))] - data[i][k+(c[1]*len(waveRanges))])
+		result[i] = make([]float64, n+len(midline)*len(EEGBands), n+len(midline)*len(EEGBands))
+		for j, c := range couples {
+			for k := 0; k < len(EEGBands); k++ {
+				result[i][k+j*len(EEGBands)] = math.Abs(data[i][k+(c[0]*len(EEGBands))] - data[i][k+(c[1]*len(EEGBands))])
 			}
 		}
-		for k := 0; k < len(waveRanges); k++ {
-			result[i][n+k] = data[i][k+4*len(waveRanges)]
-			result[i][n+len(waveRanges)+k] = data[i][k+7*len(waveRanges)]
-			result[i][n+2*len(waveRanges)+k] = data[i][k+10*len(waveRanges)]
+		for j, m := range midline {
+			for k := 0; k < len(EEGBands); k++ {
+				result[i][n+j*len(EEGBands)+k] = data[i][k+m*len(EEGBands)]
+			}
 		}
 	}
-	return result
+	return result, nil
 }
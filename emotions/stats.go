@@ -0,0 +1,221 @@
+package emotions
+
+import "math"
+
+// RunningStats accumulates the mean, the variance, the covariance (if asked for), the minimum and the maximum
+// of every dimension of vectors in a single pass
+// It uses the updates of Welford and Chan et al., which keep their precision unlike sums of x and x²,
+// and the stats of shards of the data can be merged
+// The vectors can be weighted (see AddWeighted), the moments are those of the weighted distribution
+// Only the moments up to the second are tracked, the skewness and the kurtosis (see columnFunctionals and excessKurtosis)
+// take a second pass over the data around the mean, since their running updates don't merge as simply
+type RunningStats struct {
+	n int
+	// weight is the sum of the weights of the vectors
+	weight float64
+	mean   []float64
+	// m2 is the sum of the squared deviations from the mean
+	m2 []float64
+	// comoment is the sum of the products of the deviations, nil if the covariance is not tracked
+	comoment [][]float64
+	// delta is the scratch space for the deviations of the vector added to the comoment
+	delta []float64
+	min      []float64
+	max      []float64
+}
+
+// NewRunningStats returns empty stats of vectors with dim coordinates, which track their covariance (quadratic in dim) if asked to
+func NewRunningStats(dim int, covariance bool) *RunningStats {
+	s := &RunningStats{
+		mean: make([]float64, dim, dim),
+		m2:   make([]float64, dim, dim),
+		min:  make([]float64, dim, dim),
+		max:  make([]float64, dim, dim),
+	}
+	for j := 0; j < dim; j++ {
+		s.min[j] = math.Inf(1)
+		s.max[j] = math.Inf(-1)
+	}
+	if covariance {
+		s.delta = make([]float64, dim, dim)
+		s.comoment = make([][]float64, dim, dim)
+		for i := range s.comoment {
+			s.comoment[i] = make([]float64, dim, dim)
+		}
+	}
+	return s
+}
+
+// StatsOf returns the stats (without covariance) of the vectors, which must not be empty
+func StatsOf(x [][]float64) *RunningStats {
+	s := NewRunningStats(len(x[0]), false)
+	for _, v := range x {
+		s.Add(v)
+	}
+	return s
+}
+
+// statsOfValues returns the stats of the numbers as vectors of one coordinate
+func statsOfValues(x []float64) *RunningStats {
+	s := NewRunningStats(1, false)
+	value := make([]float64, 1, 1)
+	for _, v := range x {
+		value[0] = v
+		s.Add(value)
+	}
+	return s
+}
+
+// statsOfShards returns the stats of the vectors computed in shards by the workers (see parallel)
+// The shards are merged in order, so the result doesn't depend on the number of workers
+func statsOfShards(x [][]float64, workers int) *RunningStats {
+	shards := make([]*RunningStats, numShards(len(x)), numShards(len(x)))
+	parallel(len(x), workers, func(shard int, from int, to int) {
+		shards[shard] = StatsOf(x[from:to])
+	})
+
+	s := NewRunningStats(len(x[0]), false)
+	for _, shard := range shards {
+		s.Merge(shard)
+	}
+	return s
+}
+
+// Add adds a vector with the dimension of the stats
+func (s *RunningStats) Add(x []float64) {
+	s.AddWeighted(x, 1)
+}
+
+// AddWeighted adds a vector which counts w times in the moments (West's update), w must not be negative
+// A vector of weight 0 changes only the count, the minimum and the maximum
+func (s *RunningStats) AddWeighted(x []float64, w float64) {
+	if len(x) != len(s.mean) {
+		panic("the vector doesn't have the dimension of the stats")
+	}
+	if w < 0 {
+		panic("the weight of the vector is negative")
+	}
+
+	s.n++
+	s.weight += w
+	for j, v := range x {
+		s.min[j] = math.Min(s.min[j], v)
+		s.max[j] = math.Max(s.max[j], v)
+	}
+	if w == 0 {
+		return
+	}
+
+	for j, v := range x {
+		delta := v - s.mean[j]
+		if s.comoment != nil {
+			s.delta[j] = delta
+		}
+		s.mean[j] += delta * w / s.weight
+		s.m2[j] += w * delta * (v - s.mean[j])
+	}
+
+	for i := range s.comoment {
+		for j := range s.comoment[i] {
+			s.comoment[i][j] += w * s.delta[i] * (x[j] - s.mean[j])
+		}
+	}
+}
+
+// Merge adds all the vectors of the other stats, which must have the same dimension
+// The covariance is kept only if both track it
+func (s *RunningStats) Merge(other *RunningStats) {
+	if len(other.mean) != len(s.mean) {
+		panic("the stats don't have the same dimension")
+	}
+	if other.n == 0 {
+		return
+	}
+	if s.comoment != nil && other.comoment == nil {
+		s.comoment = nil
+		s.delta = nil
+	}
+
+	for j := range s.mean {
+		s.min[j] = math.Min(s.min[j], other.min[j])
+		s.max[j] = math.Max(s.max[j], other.max[j])
+	}
+	s.n += other.n
+	if other.weight == 0 {
+		return
+	}
+
+	na, nb := s.weight, other.weight
+	n := na + nb
+	delta := minused(other.mean, s.mean)
+	for j := range s.mean {
+		s.mean[j] += delta[j] * nb / n
+		s.m2[j] += other.m2[j] + delta[j]*delta[j]*na*nb/n
+	}
+	for i := range s.comoment {
+		for j := range s.comoment[i] {
+			s.comoment[i][j] += other.comoment[i][j] + delta[i]*delta[j]*na*nb/n
+		}
+	}
+	s.weight = n
+}
+
+// Count returns the number of vectors
+func (s *RunningStats) Count() int {
+	return s.n
+}
+
+// Weight returns the sum of the weights of the vectors, which is Count if they were added without weights
+func (s *RunningStats) Weight() float64 {
+	return s.weight
+}
+
+// Mean returns the mean of every dimension
+func (s *RunningStats) Mean() []float64 {
+	return append([]float64{}, s.mean...)
+}
+
+// Variance returns the (population) variance of every dimension, 0 for no vectors
+func (s *RunningStats) Variance() []float64 {
+	variance := make([]float64, len(s.m2), len(s.m2))
+	if s.weight > 0 {
+		for j := range variance {
+			variance[j] = s.m2[j] / s.weight
+		}
+	}
+	return variance
+}
+
+// StandardDeviation returns the square root of the variance of every dimension
+func (s *RunningStats) StandardDeviation() []float64 {
+	σ := s.Variance()
+	getSqrt(&σ)
+	return σ
+}
+
+// Covariance returns the (population) covariance matrix of the dimensions
+// It panics if the stats don't track it
+func (s *RunningStats) Covariance() [][]float64 {
+	if s.comoment == nil {
+		panic("the stats don't track the covariance")
+	}
+
+	covariance := make([][]float64, len(s.comoment), len(s.comoment))
+	for i := range covariance {
+		covariance[i] = append([]float64{}, s.comoment[i]...)
+		if s.weight > 0 {
+			divide(&covariance[i], s.weight)
+		}
+	}
+	return covariance
+}
+
+// Min returns the smallest value of every dimension, +Inf for no vectors
+func (s *RunningStats) Min() []float64 {
+	return append([]float64{}, s.min...)
+}
+
+// Max returns the largest value of every dimension, -Inf for no vectors
+func (s *RunningStats) Max() []float64 {
+	return append([]float64{}, s.max...)
+}
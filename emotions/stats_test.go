@@ -0,0 +1,102 @@
+package emotions
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunningStats(t *testing.T) {
+	x := [][]float64{{1, 2}, {3, 6}, {5, 4}, {7, 8}}
+
+	s := NewRunningStats(2, true)
+	for _, v := range x {
+		s.Add(v)
+	}
+	assert.Equal(t, 4, s.Count())
+	assert.InDeltaSlice(t, []float64{4, 5}, s.Mean(), 1e-12)
+	assert.InDeltaSlice(t, []float64{5, 5}, s.Variance(), 1e-12)
+	assert.InDeltaSlice(t, []float64{5, 4}, s.Covariance()[0], 1e-12)
+	assert.Equal(t, []float64{1, 2}, s.Min())
+	assert.Equal(t, []float64{7, 8}, s.Max())
+
+	// the shards merged give the same stats
+	a := NewRunningStats(2, true)
+	b := NewRunningStats(2, true)
+	a.Add(x[0])
+	for _, v := range x[1:] {
+		b.Add(v)
+	}
+	a.Merge(b)
+	a.Merge(NewRunningStats(2, true))
+	assert.Equal(t, 4, a.Count())
+	assert.InDeltaSlice(t, s.Mean(), a.Mean(), 1e-12)
+	assert.InDeltaSlice(t, s.Variance(), a.Variance(), 1e-12)
+	assert.InDeltaSlice(t, s.Covariance()[1], a.Covariance()[1], 1e-12)
+	assert.Equal(t, s.Min(), a.Min())
+	assert.Equal(t, s.Max(), a.Max())
+
+	assert.Panics(t, func() { StatsOf(x).Covariance() })
+}
+
+func TestRunningStatsWeighted(t *testing.T) {
+	// a weight of 2 is the same as adding the vector twice
+	weighted := NewRunningStats(2, true)
+	weighted.AddWeighted([]float64{1, 2}, 2)
+	weighted.AddWeighted([]float64{3, 6}, 1)
+	weighted.AddWeighted([]float64{100, 100}, 0)
+
+	repeated := StatsOf([][]float64{{1, 2}, {1, 2}, {3, 6}})
+	assert.Equal(t, 3, weighted.Count())
+	assert.Equal(t, 3.0, weighted.Weight())
+	assert.InDeltaSlice(t, repeated.Mean(), weighted.Mean(), 1e-12)
+	assert.InDeltaSlice(t, repeated.Variance(), weighted.Variance(), 1e-12)
+	assert.InDeltaSlice(t, []float64{8.0 / 9, 16.0 / 9}, weighted.Covariance()[0], 1e-12)
+	assert.Equal(t, []float64{100, 100}, weighted.Max())
+
+	other := NewRunningStats(2, true)
+	other.AddWeighted([]float64{5, 4}, 0.5)
+	weighted.Merge(other)
+	repeated.AddWeighted([]float64{5, 4}, 0.5)
+	assert.Equal(t, 3.5, weighted.Weight())
+	assert.InDeltaSlice(t, repeated.Mean(), weighted.Mean(), 1e-12)
+	assert.InDeltaSlice(t, repeated.Variance(), weighted.Variance(), 1e-12)
+}
+
+func TestRunningStatsAllocations(t *testing.T) {
+	// adding a vector is on the hot paths of k-means and the eeg preprocessing
+	x := []float64{1, 2, 3}
+	for _, covariance := range []bool{false, true} {
+		s := NewRunningStats(len(x), covariance)
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { s.Add(x) }))
+	}
+}
+
+func TestRunningStatsPrecision(t *testing.T) {
+	// sums of x and x² lose the variance of small changes around a large offset
+	random := rand.New(rand.NewSource(1))
+	x := make([][]float64, 10000, 10000)
+	for i := range x {
+		x[i] = []float64{1e9 + random.NormFloat64()}
+	}
+
+	assert.InDelta(t, 1, StatsOf(x).Variance()[0], 0.05)
+	assert.InDeltaSlice(t, StatsOf(x).Variance(), statsOfShards(x, 1).Variance(), 1e-6)
+	assert.Equal(t, statsOfShards(x, 1).Variance(), statsOfShards(x, 4).Variance())
+}
+
+func TestGetμAndσTagged(t *testing.T) {
+	tagged := []Tagged{
+		{Tag: "happy", Data: [][]float64{{1, 5}, {3, 5}}},
+		{Tag: "sad", Data: [][]float64{{5, 5}}},
+	}
+
+	μ, σ := GetμAndσTagged(tagged)
+	assert.InDeltaSlice(t, []float64{3, 5}, μ, 1e-12)
+	assert.InDelta(t, 8.0/3, σ[0], 1e-12)
+	// a constant coordinate still divides the distances by something
+	assert.Equal(t, EPS, σ[1])
+	assert.False(t, math.IsInf(mahalanobisDistance([]float64{1, 5}, []float64{3, 5}, σ), 0))
+}
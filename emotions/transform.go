@@ -77,6 +77,19 @@ func (c Complex) add(o Complex) Complex {
 	}
 }
 
+func (c Complex) sub(o Complex) Complex {
+	return Complex{
+		Re: c.Re - o.Re,
+		Im: c.Im - o.Im,
+	}
+}
+
+// quotient returns c1 / c2
+func quotient(c1, c2 Complex) Complex {
+	// (a + bi) / (c + di) = (a + bi)(c - di) / (c^2 + d^2)
+	return dot(c1, c2.conjugate()).divide(Power(c2))
+}
+
 // e^(⁻² ᵖᶦ ᶦᵏʲ/ᴺ)
 func e(k, j int, n int) Complex {
 	return Complex{
@@ -257,7 +257,10 @@ func sliceCopy(first []float64, from, to, length int) []float64 {
 }
 
 func PlotEeg(filename string, output string) {
-	ts := getEegTrainingSet(filename)
+	ts, err := getEegTrainingSet(filename)
+	if err != nil {
+		panic(err)
+	}
 	plotEeg(ts, output)
 }
 
@@ -269,23 +272,13 @@ func plotEeg(data []EegClusterable, file string) {
 		panic(err)
 	}
 
-	maximums := make([]float64, len(data[0].Data), len(data[0].Data))
+	maximums := make([]float64, len(EEGBands), len(EEGBands))
 	for i := 0; i < len(data); i++ {
 		for j := 0; j < len(data[i].Data); j++ {
-			if data[i].Data[j][0] > maximums[0] {
-				maximums[0] = data[i].Data[j][0]
-			}
-
-			if data[i].Data[j][1] > maximums[1] {
-				maximums[1] = data[i].Data[j][1]
-			}
-
-			if data[i].Data[j][2] > maximums[2] {
-				maximums[2] = data[i].Data[j][2]
-			}
-
-			if data[i].Data[j][3] > maximums[3] {
-				maximums[3] = data[i].Data[j][3]
+			for k := range maximums {
+				if data[i].Data[j][k] > maximums[k] {
+					maximums[k] = data[i].Data[j][k]
+				}
 			}
 		}
 	}
@@ -318,19 +311,20 @@ func plotEeg(data []EegClusterable, file string) {
 }
 
 func PlotEmotion(filename string, output string) {
-	data := ReadXML(filename, 19)
+	rec := readEEG(filename, AllElectrodes)
+	data := rec.Data
 
 	var cl []EegClusterable
 
 	for i, d := range data {
 		var features [][]float64
-		frames := cutElectrodeIntoFrames(d, 200, 150, false)
+		frames := cutElectrodeIntoFrames(d, rec.SampleRate, 200, 150, false)
 		fouriers := fourierElectrode(frames)
 		for _, f := range fouriers {
-			v := make([]float64, 4, 4)
+			v := make([]float64, len(EEGBands), len(EEGBands))
 			for _, ff := range f {
 				magnitude := Magnitude(ff)
-				w := getRange(magnitude)
+				w := EEGBands.Index(magnitude)
 				if w == -1 {
 					break
 				}
@@ -354,21 +348,50 @@ func PlotEmotion(filename string, output string) {
 	plotEeg(cl, output)
 }
 
+// getColour mixes the colours of the bands weighted by their power relative to the maximums
+// the more power there is overall, the more opaque the colour is
 func getColour(x []float64, maximums []float64) color.RGBA {
-	r := uint8(math.Min(math.Floor(x[0]*float64(255)/maximums[0]), 255))
-	g := uint8(math.Min(math.Floor(x[1]*float64(255)/maximums[1]), 255))
-	b := uint8(math.Min(math.Floor(x[2]*float64(255)/maximums[2]), 255))
-	a := uint8(math.Min(math.Floor(x[3]*float64(155)/maximums[3]+100), 255))
+	var r, g, b, total float64
+	for k := range x {
+		intensity := math.Min(x[k]/maximums[k], 1)
+		br, bg, bb := bandColour(k, len(x))
+		r += intensity * br
+		g += intensity * bg
+		b += intensity * bb
+		total += intensity
+	}
 
-	// if r == 255 || g == 255 || b == 255 || a == 255 {
-	// fmt.Printf("x: %v, r: %d g: %d b: %d a: %d\n", x, r, g, b, a)
-	// }
+	if total > 0 {
+		r /= total
+		g /= total
+		b /= total
+	}
 
 	return color.RGBA{
-		R: r,
-		G: g,
-		B: b,
-		A: a,
+		R: uint8(math.Floor(r * 255)),
+		G: uint8(math.Floor(g * 255)),
+		B: uint8(math.Floor(b * 255)),
+		A: uint8(math.Min(math.Floor(total*float64(155)/float64(len(x))+100), 255)),
+	}
+}
+
+// bandColour returns the rgb (in [0, 1]) of the k-th of n bands, evenly spread over the hues from red to violet
+func bandColour(k int, n int) (float64, float64, float64) {
+	hue := 300.0 * float64(k) / math.Max(float64(n-1), 1)
+	sector := hue / 60
+	x := 1 - math.Abs(math.Mod(sector, 2)-1)
+
+	switch int(sector) {
+	case 0:
+		return 1, x, 0
+	case 1:
+		return x, 1, 0
+	case 2:
+		return 0, 1, x
+	case 3:
+		return 0, x, 1
+	default:
+		return x, 0, 1
 	}
 }
 
@@ -508,6 +531,30 @@ func GetAlphaEGMs(dirname string) ([]AlphaEGM, error) {
 	return egms, nil
 }
 
+// replaceFile writes the bytes to a temporary file next to filename and renames it,
+// so a crash never leaves a partly written file behind
+func replaceFile(filename string, bytes []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(bytes)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
 func GetEGMs(dirname string) ([]EmotionGausianMixure, error) {
 	files, err := ioutil.ReadDir(dirname)
 	if err != nil {
@@ -537,22 +584,28 @@ func GetMagnitute(c []Complex, n int) []float64 {
 func GetPower(c []Complex, n int) []float64 {
 	data := make([]float64, n, n)
 	for i := 0; i < len(data); i++ {
-		data[i] = math.Log(Power(c[i]))
+		data[i] = safeLog(Power(c[i]))
 	}
 	return data
 }
 
+// speechFeatures returns the mfccs for a single wav file going through the feature cache
+func speechFeatures(filename string) [][]float64 {
+	config := featureConfig("mfcc C=%d M=%d dither=%g preemphasis=%g", 13, 23, 0.01, 0.97)
+	return cachedFeatures(filename, config, func() [][]float64 {
+		wf, _ := Read(filename, 0.01, 0.97)
+		return checkFeatures(filename, MFCCs(wf, 13, 23))
+	})
+}
+
 func ReadSpeechFeaturesOne(filename string) [][]float64 {
-	wf, _ := Read(filename, 0.01, 0.97)
-	return MFCCs(wf, 13, 23)
+	return speechFeatures(filename)
 }
 
 func ReadSpeechFeatures(filenames []string) [][]float64 {
 	mfccs := make([][]float64, 0, len(filenames)*100)
 	for _, f := range filenames {
-		wf, _ := Read(f, 0.01, 0.97)
-
-		mfcc := MFCCs(wf, 13, 23)
+		mfcc := speechFeatures(f)
 		mfccs = append(mfccs, mfcc...)
 	}
 
@@ -562,9 +615,7 @@ func ReadSpeechFeatures(filenames []string) [][]float64 {
 func ReadSpeechFeaturesAppend(filenames []string, features *[]([][]float64)) [][]float64 {
 	mfccs := make([][]float64, 0, len(filenames)*100)
 	for _, f := range filenames {
-		wf, _ := Read(f, 0.01, 0.97)
-
-		mfcc := MFCCs(wf, 13, 23)
+		mfcc := speechFeatures(f)
 		mfccs = append(mfccs, mfcc...)
 		*features = append(*features, mfcc)
 	}
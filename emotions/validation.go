@@ -0,0 +1,178 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// LogFloor is the smallest energy whose logarithm is taken during feature extraction
+// Digital silence would otherwise give -Inf which propagates into KMeans and em
+var LogFloor = 1e-10
+
+func safeLog(x float64) float64 {
+	if x < LogFloor {
+		return math.Log(LogFloor)
+	}
+	return math.Log(x)
+}
+
+// NonFinitePolicy tells what to do with features which are NaN or ±Inf
+type NonFinitePolicy int
+
+const (
+	// NonFiniteDrop removes every frame that has a non-finite coordinate
+	NonFiniteDrop NonFinitePolicy = iota
+	// NonFiniteClamp replaces ±Inf with the largest/smallest finite value in the same dimension and NaN with the mean of the dimension
+	NonFiniteClamp
+	// NonFiniteFail rejects the whole matrix
+	NonFiniteFail
+)
+
+func (p NonFinitePolicy) String() string {
+	switch p {
+	case NonFiniteDrop:
+		return "drop"
+	case NonFiniteClamp:
+		return "clamp"
+	case NonFiniteFail:
+		return "fail"
+	default:
+		return fmt.Sprintf("NonFinitePolicy(%d)", int(p))
+	}
+}
+
+// FeaturePolicy is the policy used by the feature entry points (ReadSpeechFeatures, GetFourierForFile...)
+var FeaturePolicy = NonFiniteClamp
+
+// NonFinite describes a single non-finite value in a feature matrix
+type NonFinite struct {
+	File      string
+	Frame     int
+	Dimension int
+	Value     float64
+}
+
+func (n NonFinite) String() string {
+	return fmt.Sprintf("%s: frame %d dimension %d: %f", n.File, n.Frame, n.Dimension, n.Value)
+}
+
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+// FindNonFinite returns all the NaN and ±Inf values in the features of the given file
+func FindNonFinite(file string, features [][]float64) []NonFinite {
+	var found []NonFinite
+	for i := range features {
+		for j, v := range features[i] {
+			if !isFinite(v) {
+				found = append(found, NonFinite{File: file, Frame: i, Dimension: j, Value: v})
+			}
+		}
+	}
+	return found
+}
+
+// ValidateFeatures checks the features (frames x dims) extracted from the given file for NaN and ±Inf
+// and handles them according to the policy
+// It returns the (possibly changed) features and all the non-finite values that were found
+// The error is not nil only for NonFiniteFail when something was found
+func ValidateFeatures(file string, features [][]float64, policy NonFinitePolicy) ([][]float64, []NonFinite, error) {
+	found := FindNonFinite(file, features)
+	if len(found) == 0 {
+		return features, nil, nil
+	}
+
+	switch policy {
+	case NonFiniteDrop:
+		return dropFrames(features, found), found, nil
+	case NonFiniteClamp:
+		clampNonFinite(features, found)
+		return features, found, nil
+	default:
+		return nil, found, fmt.Errorf("%d non-finite values in %d frames, first is %s", len(found), len(frameIndices(found)), found[0])
+	}
+}
+
+func frameIndices(found []NonFinite) []int {
+	frames := make([]int, 0, len(found))
+	for _, f := range found {
+		if len(frames) == 0 || frames[len(frames)-1] != f.Frame {
+			frames = append(frames, f.Frame)
+		}
+	}
+	return frames
+}
+
+func dropFrames(features [][]float64, found []NonFinite) [][]float64 {
+	bad := make(map[int]struct{})
+	for _, f := range found {
+		bad[f.Frame] = struct{}{}
+	}
+
+	kept := make([][]float64, 0, len(features)-len(bad))
+	for i := range features {
+		if _, ok := bad[i]; !ok {
+			kept = append(kept, features[i])
+		}
+	}
+	return kept
+}
+
+func clampNonFinite(features [][]float64, found []NonFinite) {
+	dims := len(features[0])
+	mins := make([]float64, dims, dims)
+	maxs := make([]float64, dims, dims)
+	means := make([]float64, dims, dims)
+	counts := make([]int, dims, dims)
+
+	for j := 0; j < dims; j++ {
+		mins[j] = math.Inf(1)
+		maxs[j] = math.Inf(-1)
+	}
+
+	for i := range features {
+		for j, v := range features[i] {
+			if isFinite(v) {
+				mins[j] = math.Min(mins[j], v)
+				maxs[j] = math.Max(maxs[j], v)
+				means[j] += v
+				counts[j]++
+			}
+		}
+	}
+
+	for _, f := range found {
+		j := f.Dimension
+		// a dimension without a single finite value gets zeroes
+		if counts[j] == 0 {
+			features[f.Frame][j] = 0
+			continue
+		}
+
+		switch {
+		case math.IsInf(f.Value, 1):
+			features[f.Frame][j] = maxs[j]
+		case math.IsInf(f.Value, -1):
+			features[f.Frame][j] = mins[j]
+		default:
+			features[f.Frame][j] = means[j] / float64(counts[j])
+		}
+	}
+}
+
+// checkFeatures applies FeaturePolicy to the features extracted from the given file
+// and reports the frames with non-finite values on stderr
+func checkFeatures(file string, features [][]float64) [][]float64 {
+	checked, found, err := ValidateFeatures(file, features, FeaturePolicy)
+	if err != nil {
+		panic(fmt.Sprintf("%s: %s", file, err))
+	}
+
+	if len(found) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d non-finite values in frames %v (%s)\n", file, len(found), frameIndices(found), FeaturePolicy)
+	}
+
+	return checked
+}
@@ -0,0 +1,33 @@
+package emotions
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nonFiniteFeatures() [][]float64 {
+	return [][]float64{
+		[]float64{1.0, 2.0},
+		[]float64{math.Inf(-1), 4.0},
+		[]float64{3.0, math.NaN()},
+	}
+}
+
+func TestValidateFeatures(t *testing.T) {
+	dropped, found, err := ValidateFeatures("a.wav", nonFiniteFeatures(), NonFiniteDrop)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(found))
+	assert.Equal(t, [][]float64{[]float64{1.0, 2.0}}, dropped)
+
+	clamped, _, err := ValidateFeatures("a.wav", nonFiniteFeatures(), NonFiniteClamp)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]float64{[]float64{1.0, 2.0}, []float64{1.0, 4.0}, []float64{3.0, 3.0}}, clamped)
+
+	_, found, err = ValidateFeatures("a.wav", nonFiniteFeatures(), NonFiniteFail)
+	assert.NotNil(t, err)
+	assert.Equal(t, NonFinite{File: "a.wav", Frame: 1, Dimension: 0, Value: math.Inf(-1)}, found[0])
+
+	assert.InDelta(t, math.Log(LogFloor), safeLog(0), EPS)
+}
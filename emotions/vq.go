@@ -0,0 +1,286 @@
+package emotions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// Codebook quantises frames to the closest of its codewords with variance scaled distances (see mahalanobisDistance)
+type Codebook struct {
+	Codewords [][]float64
+	Variances []float64
+}
+
+// NewCodebook clusters the frames into k codewords with KMeansWithOptions
+// The distances are scaled by the variances of the frames
+func NewCodebook(frames [][]float64, k int, options KMeansOptions) (Codebook, error) {
+	if err := checkFrames(frames, k); err != nil {
+		return Codebook{}, err
+	}
+	return newCodebook(frames, k, scalingVariances(frames, options.Workers), options), nil
+}
+
+// newCodebook is NewCodebook with the distances scaled by the given variances
+// both while clustering and while quantising
+func newCodebook(frames [][]float64, k int, variances []float64, options KMeansOptions) Codebook {
+	return Codebook{
+		Codewords: kMeansWithVariances(frames, k, variances, options).Expectations,
+		Variances: variances,
+	}
+}
+
+// checkFrames checks that there are at least k frames of the same dimension
+func checkFrames(frames [][]float64, k int) error {
+	if len(frames) < k {
+		return fmt.Errorf("%d frames for %d codewords", len(frames), k)
+	}
+	for i, f := range frames {
+		if len(f) != len(frames[0]) {
+			return fmt.Errorf("frame %d has %d coordinates instead of %d", i, len(f), len(frames[0]))
+		}
+	}
+	return nil
+}
+
+// check makes sure that every codeword can be compared with frames of the dimension of the variances
+func (c Codebook) check() error {
+	if len(c.Codewords) == 0 {
+		return fmt.Errorf("no codewords")
+	}
+	for i, w := range c.Codewords {
+		if len(w) != len(c.Variances) {
+			return fmt.Errorf("codeword %d has %d coordinates instead of %d", i, len(w), len(c.Variances))
+		}
+	}
+	return nil
+}
+
+// Quantise returns the closest codeword to the frame and the distortion, its squared scaled distance
+func (c Codebook) Quantise(frame []float64) (int, float64) {
+	closest := findClosestCentroid(c.Codewords, frame, c.Variances)
+	return int(closest), mahalanobisDistance(frame, c.Codewords[closest], c.Variances)
+}
+
+// Distortion returns the mean distortion of the frames
+func (c Codebook) Distortion(frames [][]float64) float64 {
+	var sum float64
+	for _, f := range frames {
+		_, d := c.Quantise(f)
+		sum += d
+	}
+	return sum / float64(len(frames))
+}
+
+// Histogram returns the part of the frames quantised to every codeword
+func (c Codebook) Histogram(frames [][]float64) []float64 {
+	histogram := make([]float64, len(c.Codewords), len(c.Codewords))
+	for _, f := range frames {
+		i, _ := c.Quantise(f)
+		histogram[i]++
+	}
+	divide(&histogram, float64(len(frames)))
+	return histogram
+}
+
+// chiSquared returns the χ² distance ½ Σ (p - q)² / (p + q) of two histograms
+func chiSquared(p []float64, q []float64) float64 {
+	var sum float64
+	for i := range p {
+		if p[i]+q[i] > 0 {
+			sum += (p[i] - q[i]) * (p[i] - q[i]) / (p[i] + q[i])
+		}
+	}
+	return sum / 2
+}
+
+// VQMode is the way a VQClassifier compares an utterance with the emotions
+type VQMode int
+
+const (
+	// VQDistortion quantises the utterance with the codebook of every emotion and picks the one with the lowest mean distortion
+	VQDistortion VQMode = iota
+	// VQHistogram quantises the utterance with a codebook shared by all the emotions
+	// and picks the emotion whose histogram of codewords is the closest to that of the utterance
+	VQHistogram
+)
+
+func (m VQMode) String() string {
+	switch m {
+	case VQDistortion:
+		return "distortion"
+	case VQHistogram:
+		return "histogram"
+	default:
+		return fmt.Sprintf("VQMode(%d)", int(m))
+	}
+}
+
+// VQClassifier tells the emotion of utterances by vector quantisation, a fast baseline for the GMM classifier
+type VQClassifier struct {
+	Mode VQMode
+	// Codebooks are the codebooks of the emotions with VQDistortion
+	Codebooks map[string]Codebook `json:",omitempty"`
+	// Shared is the codebook of all the emotions with VQHistogram
+	Shared *Codebook `json:",omitempty"`
+	// Histograms are the histograms of the shared codewords for the emotions with VQHistogram
+	Histograms map[string][]float64 `json:",omitempty"`
+}
+
+// TrainVQ trains codebooks of k codewords on the frames of every emotion
+// All the codebooks scale the distances by the variances of the frames of all the emotions
+func TrainVQ(emotionFrames map[string][][]float64, k int, mode VQMode, options KMeansOptions) (*VQClassifier, error) {
+	if len(emotionFrames) == 0 {
+		return nil, fmt.Errorf("no emotions to train")
+	}
+	emotions := make([]string, 0, len(emotionFrames))
+	for e := range emotionFrames {
+		emotions = append(emotions, e)
+	}
+	sort.Strings(emotions)
+
+	var all [][]float64
+	for _, e := range emotions {
+		if len(emotionFrames[e]) == 0 {
+			return nil, fmt.Errorf("%s: no frames", e)
+		}
+		all = append(all, emotionFrames[e]...)
+	}
+	if err := checkFrames(all, k); err != nil {
+		return nil, err
+	}
+	// all the codebooks measure the distortion in the same units
+	// otherwise the emotions with a larger spread would have lower distortions
+	variances := scalingVariances(all, options.Workers)
+
+	switch mode {
+	case VQDistortion:
+		classifier := &VQClassifier{Mode: mode, Codebooks: make(map[string]Codebook, len(emotions))}
+		for _, e := range emotions {
+			if err := checkFrames(emotionFrames[e], k); err != nil {
+				return nil, fmt.Errorf("%s: %s", e, err)
+			}
+			classifier.Codebooks[e] = newCodebook(emotionFrames[e], k, variances, options)
+		}
+		return classifier, nil
+	case VQHistogram:
+		codebook := newCodebook(all, k, variances, options)
+		classifier := &VQClassifier{Mode: mode, Shared: &codebook, Histograms: make(map[string][]float64, len(emotions))}
+		for _, e := range emotions {
+			classifier.Histograms[e] = codebook.Histogram(emotionFrames[e])
+		}
+		return classifier, nil
+	default:
+		return nil, fmt.Errorf("unknown vq mode %s", mode)
+	}
+}
+
+// Classify returns the emotion of the frames of an utterance and the score of every emotion
+// which is the mean distortion or the χ² distance of the histograms, so lower is closer
+func (v *VQClassifier) Classify(frames [][]float64) (string, map[string]float64, error) {
+	if len(frames) == 0 {
+		return "", nil, fmt.Errorf("no frames to classify")
+	}
+
+	scores := make(map[string]float64)
+	switch v.Mode {
+	case VQDistortion:
+		for e, codebook := range v.Codebooks {
+			if err := checkDimension(frames, codebook); err != nil {
+				return "", nil, err
+			}
+			scores[e] = codebook.Distortion(frames)
+		}
+	case VQHistogram:
+		if v.Shared == nil {
+			return "", nil, fmt.Errorf("no shared codebook")
+		}
+		if err := checkDimension(frames, *v.Shared); err != nil {
+			return "", nil, err
+		}
+		histogram := v.Shared.Histogram(frames)
+		for e, h := range v.Histograms {
+			scores[e] = chiSquared(histogram, h)
+		}
+	default:
+		return "", nil, fmt.Errorf("unknown vq mode %s", v.Mode)
+	}
+	if len(scores) == 0 {
+		return "", nil, fmt.Errorf("no emotions to classify with")
+	}
+
+	// the ties go to the first emotion in alphabetical order
+	best := ""
+	min := math.Inf(1)
+	for e, score := range scores {
+		if score < min || (score == min && e < best) {
+			min = score
+			best = e
+		}
+	}
+	return best, scores, nil
+}
+
+func checkDimension(frames [][]float64, codebook Codebook) error {
+	for i, f := range frames {
+		if len(f) != len(codebook.Variances) {
+			return fmt.Errorf("frame %d has %d coordinates and the codebook %d", i, len(f), len(codebook.Variances))
+		}
+	}
+	return nil
+}
+
+// Save writes the classifier as json, replacing the file only after it is fully written
+func (v *VQClassifier) Save(filename string) error {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return replaceFile(filename, bytes)
+}
+
+// LoadVQClassifier reads a classifier written by Save
+func LoadVQClassifier(filename string) (*VQClassifier, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var v VQClassifier
+	if err := json.Unmarshal(bytes, &v); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if err := v.check(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return &v, nil
+}
+
+// check makes sure that Classify can't go out of the codewords or the histograms
+func (v *VQClassifier) check() error {
+	switch v.Mode {
+	case VQDistortion:
+		for e, codebook := range v.Codebooks {
+			if err := codebook.check(); err != nil {
+				return fmt.Errorf("%s: %s", e, err)
+			}
+		}
+	case VQHistogram:
+		if v.Shared == nil {
+			return fmt.Errorf("no shared codebook")
+		}
+		if err := v.Shared.check(); err != nil {
+			return err
+		}
+		for e, h := range v.Histograms {
+			if len(h) != len(v.Shared.Codewords) {
+				return fmt.Errorf("%s: histogram of %d codewords instead of %d", e, len(h), len(v.Shared.Codewords))
+			}
+		}
+	default:
+		return fmt.Errorf("unknown vq mode %s", v.Mode)
+	}
+	return nil
+}
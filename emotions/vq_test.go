@@ -0,0 +1,125 @@
+package emotions
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// emotionFrames returns frames of every emotion around its own two centres
+func emotionFrames(random *rand.Rand, n int) map[string][][]float64 {
+	centres := map[string][][]float64{
+		"happy": {{0, 0}, {4, 0}},
+		"sad":   {{0, 8}, {4, 8}},
+		"angry": {{8, 0}, {8, 4}},
+	}
+
+	frames := make(map[string][][]float64)
+	for e, cs := range centres {
+		for i := 0; i < n; i++ {
+			c := cs[i%len(cs)]
+			frames[e] = append(frames[e], []float64{c[0] + random.NormFloat64()*0.5, c[1] + random.NormFloat64()*0.5})
+		}
+	}
+	return frames
+}
+
+func TestVQClassifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vq")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	random := rand.New(rand.NewSource(5))
+	train := emotionFrames(random, 200)
+	test := emotionFrames(random, 20)
+
+	for _, mode := range []VQMode{VQDistortion, VQHistogram} {
+		options := DefaultKMeansOptions()
+		options.Init = InitKMeansPP
+		options.Rand = rand.New(rand.NewSource(1))
+		k := 2
+		if mode == VQHistogram {
+			k = 6
+		}
+
+		classifier, err := TrainVQ(train, k, mode, options)
+		assert.Nil(t, err)
+
+		filename := filepath.Join(dir, mode.String()+".json")
+		assert.Nil(t, classifier.Save(filename))
+		loaded, err := LoadVQClassifier(filename)
+		assert.Nil(t, err)
+		assert.Equal(t, classifier, loaded)
+
+		for e, frames := range test {
+			best, scores, err := loaded.Classify(frames)
+			assert.Nil(t, err)
+			assert.Equal(t, e, best, mode.String())
+			assert.Len(t, scores, 3)
+		}
+	}
+
+	_, err = TrainVQ(map[string][][]float64{"happy": {{1, 2}}}, 2, VQDistortion, DefaultKMeansOptions())
+	assert.Error(t, err)
+	classifier, _ := TrainVQ(train, 2, VQDistortion, DefaultKMeansOptions())
+	_, _, err = classifier.Classify([][]float64{{1, 2, 3}})
+	assert.Error(t, err)
+}
+
+func TestCodebookHistogram(t *testing.T) {
+	codebook := Codebook{Codewords: [][]float64{{0}, {10}}, Variances: []float64{1}}
+	assert.Equal(t, []float64{0.75, 0.25}, codebook.Histogram([][]float64{{1}, {-1}, {2}, {9}}))
+	assert.InDelta(t, 1.75, codebook.Distortion([][]float64{{1}, {-1}, {2}, {9}}), 1e-12)
+	assert.InDelta(t, 0, chiSquared([]float64{0.5, 0.5}, []float64{0.5, 0.5}), 1e-12)
+	assert.InDelta(t, 1, chiSquared([]float64{1, 0}, []float64{0, 1}), 1e-12)
+}
+
+func TestVQDistortionSpread(t *testing.T) {
+	// the frames of "wide" spread much more, which must not make its distortions smaller
+	random := rand.New(rand.NewSource(2))
+	frames := func(centre float64, σ float64, n int) [][]float64 {
+		x := make([][]float64, n, n)
+		for i := range x {
+			x[i] = []float64{centre + random.NormFloat64()*σ}
+		}
+		return x
+	}
+	train := map[string][][]float64{"narrow": frames(0, 1, 500), "wide": frames(15, 20, 500)}
+
+	classifier, err := TrainVQ(train, 1, VQDistortion, DefaultKMeansOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, classifier.Codebooks["narrow"].Variances, classifier.Codebooks["wide"].Variances)
+
+	best, _, err := classifier.Classify(frames(0, 1, 50))
+	assert.Nil(t, err)
+	assert.Equal(t, "narrow", best)
+	best, _, err = classifier.Classify(frames(15, 20, 50))
+	assert.Nil(t, err)
+	assert.Equal(t, "wide", best)
+}
+
+func TestLoadVQClassifierInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vq")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	shared := Codebook{Codewords: [][]float64{{0, 0}, {1, 1}}, Variances: []float64{1, 1}}
+	short := Codebook{Codewords: [][]float64{{0, 0}, {1}}, Variances: []float64{1, 1}}
+	for _, classifier := range []VQClassifier{
+		{Mode: VQMode(7)},
+		{Mode: VQDistortion, Codebooks: map[string]Codebook{"happy": {Variances: []float64{1, 1}}}},
+		{Mode: VQDistortion, Codebooks: map[string]Codebook{"happy": short}},
+		{Mode: VQHistogram},
+		{Mode: VQHistogram, Shared: &short, Histograms: map[string][]float64{"happy": {0.5, 0.5}}},
+		{Mode: VQHistogram, Shared: &shared, Histograms: map[string][]float64{"happy": {1}}},
+	} {
+		filename := filepath.Join(dir, "vq.json")
+		assert.Nil(t, classifier.Save(filename))
+		_, err := LoadVQClassifier(filename)
+		assert.Error(t, err, "%+v", classifier)
+	}
+}